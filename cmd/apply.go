@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dolastack/structdiff/compare"
+	"github.com/spf13/cobra"
+)
+
+var (
+	applyFormat string
+	applyWrite  bool
+)
+
+var applyCmd = &cobra.Command{
+	Use:   "apply <file> <patch>",
+	Short: "Apply a diff or patch to a structured file",
+	Long: `Apply a structdiff JSON diff (-o json), a JSON Patch (RFC 6902) or a JSON
+Merge Patch (RFC 7386) to a JSON, YAML or TOML file. The result is written
+in the format of the file, to stdout unless --write is given.`,
+	Args: cobra.ExactArgs(2),
+	Run:  runApply,
+}
+
+func runApply(cmd *cobra.Command, args []string) {
+	file, patch := args[0], args[1]
+
+	if file == "-" && patch == "-" {
+		fmt.Fprintln(os.Stderr, "Error: cannot read both the file and the patch from stdin")
+		os.Exit(1)
+	}
+	if applyWrite && file == "-" {
+		fmt.Fprintln(os.Stderr, "Error: cannot write the result back to stdin")
+		os.Exit(1)
+	}
+
+	config := remoteConfig()
+	doc, err := compare.FetchDocument(file, config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error applying patch: %v\n", err)
+		os.Exit(1)
+	}
+	if applyFormat == "auto" {
+		detected, err := compare.DetectDocumentFormat(doc)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error detecting format: %v\n", err)
+			os.Exit(1)
+		}
+		applyFormat = detected.Format
+	}
+	if err := doc.Parse(applyFormat, config, compare.ParseOptions{}); err != nil {
+		fmt.Fprintf(os.Stderr, "Error applying patch: %v\n", err)
+		os.Exit(1)
+	}
+
+	result, err := compare.ApplyDocument(doc, patch, config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error applying patch: %v\n", err)
+		os.Exit(1)
+	}
+
+	if applyWrite {
+		info, err := os.Stat(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing file: %v\n", err)
+			os.Exit(1)
+		}
+		if err := os.WriteFile(file, result, info.Mode().Perm()); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing file: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	os.Stdout.Write(result)
+}
+
+func init() {
+	applyCmd.Flags().StringVarP(&applyFormat, "format", "f", "auto", "File format (json|yaml|toml|auto)")
+	applyCmd.Flags().BoolVarP(&applyWrite, "write", "w", false, "Write the result back to the file instead of stdout")
+	addRemoteFlags(applyCmd)
+	rootCmd.AddCommand(applyCmd)
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dolastack/structdiff/compare"
+	"github.com/dolastack/structdiff/output"
+	"github.com/spf13/cobra"
+)
+
+var batchWorkers int
+
+var batchCmd = &cobra.Command{
+	Use:   "batch <manifest.yaml>",
+	Short: "Compare the file pairs listed in a manifest",
+	Long: `Compare every pair of files listed in a YAML manifest concurrently and print
+one combined report with the status of each pair. Relative paths in the
+manifest are resolved against its directory:
+
+    workers: 4
+    pairs:
+      - name: web
+        file1: rendered/web.yaml
+        file2: committed/web.yaml
+        format: yaml
+        list_keys: ["spec.containers=name"]
+        ignore: ["metadata.annotations.*"]
+
+Pairs accept the options of the root command: ignore_case, list_keys,
+csv_key, unordered, unordered_paths, ignore, only, sort, abs_tolerance and
+rel_tolerance, and the parse options xml_ignore_namespaces,
+xml_ignore_comments, xml_ignore_pi, xml_ignore_whitespace, csv_delimiter,
+csv_no_header, csv_comment, csv_lazy_quotes, csv_ragged and csv_infer_types,
+which add to the --xml-* and --csv-* flags. Exits with status 1 if any pair
+could not be compared.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runBatch,
+}
+
+func runBatch(cmd *cobra.Command, args []string) {
+	config := remoteConfig()
+	manifest, err := compare.LoadBatchManifest(args[0], config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	workers := manifest.Workers
+	if cmd.Flags().Changed("workers") {
+		workers = batchWorkers
+	}
+	results := compare.CompareBatch(manifest.Pairs, workers, config, parseOptions())
+
+	var result string
+	switch outputFormat {
+	case "json":
+		result, err = output.FormatBatchJSON(results)
+	default:
+		result, err = output.FormatBatchText(results, color)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error formatting output: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(result)
+
+	for _, r := range results {
+		if r.Status == compare.FileError {
+			os.Exit(1)
+		}
+	}
+}
+
+func init() {
+	batchCmd.Flags().IntVarP(&batchWorkers, "workers", "j", 0, "Maximum number of concurrent comparisons (default: manifest setting, else one per CPU)")
+	batchCmd.Flags().StringVarP(&outputFormat, "output", "o", "text", "Output format (text|json)")
+	batchCmd.Flags().BoolVar(&color, "color", true, "Enable colored output")
+	addRemoteFlags(batchCmd)
+	addParseFlags(batchCmd)
+	rootCmd.AddCommand(batchCmd)
+}
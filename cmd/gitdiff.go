@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dolastack/structdiff/compare"
+)
+
+// runExternalDiff handles git's GIT_EXTERNAL_DIFF calling convention:
+//
+//	path old-file old-hex old-mode new-file new-hex new-mode [new-path xfrm-msg]
+//
+// Files in a recognized format are compared structurally; anything else,
+// including added and deleted files, gets a plain text diff.
+func runExternalDiff(args []string) {
+	path, oldFile, newFile := args[0], args[1], args[4]
+	newPath := path
+	if len(args) == 9 {
+		newPath = args[7]
+	}
+
+	fmt.Printf("structdiff a/%s b/%s\n", path, newPath)
+
+	result, err := externalDiff(path, oldFile, newFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "structdiff: %s: %v, showing a text diff\n", path, err)
+		result, err = textDiff(oldFile, newFile, "a/"+path, "b/"+newPath)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error comparing files: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(result)
+}
+
+func externalDiff(path, oldFile, newFile string) (string, error) {
+	if oldFile == os.DevNull || newFile == os.DevNull {
+		return textDiff(oldFile, newFile, "a/"+path, "b/"+path)
+	}
+
+	diffFormat := format
+	if diffFormat == "auto" {
+		detected, err := compare.DetectFormat(path)
+		if err != nil {
+			return textDiff(oldFile, newFile, "a/"+path, "b/"+path)
+		}
+		diffFormat = detected
+	}
+
+	opts, err := comparisonOptions()
+	if err != nil {
+		return "", err
+	}
+	config := remoteConfig()
+	diff, doc1, doc2, err := loadAndCompare(oldFile, diffFormat, newFile, diffFormat, opts, config, parseOptions())
+	if err != nil {
+		return "", err
+	}
+	return formatDiffs(diff, doc1, doc2)
+}
+
+func textDiff(oldFile, newFile, oldName, newName string) (string, error) {
+	a, err := os.ReadFile(oldFile)
+	if err != nil {
+		return "", err
+	}
+	b, err := os.ReadFile(newFile)
+	if err != nil {
+		return "", err
+	}
+	return compare.UnifiedDiff(a, b, oldName, newName), nil
+}
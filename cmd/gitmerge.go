@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/dolastack/structdiff/compare"
+	"github.com/spf13/cobra"
+)
+
+var markerSize int
+
+var gitMergeDriverCmd = &cobra.Command{
+	Use:   "git-merge-driver <base> <current> <other> [<path>]",
+	Short: "Merge structured files as a git merge driver",
+	Long: `Merge JSON, YAML and TOML files semantically as a git merge driver. The
+result is written to <current>; conflicts are written as conflict markers
+around the lines that differ. Exits with status 0 when the merge is clean and
+1 when conflicts remain. Files in other formats, or that fail to parse, are
+merged line by line with git merge-file.
+
+Register the driver with:
+
+    git config merge.structdiff.name "structdiff structured merge"
+    git config merge.structdiff.driver "structdiff git-merge-driver %O %A %B %P --marker-size %L"
+
+and select it in .gitattributes:
+
+    *.json merge=structdiff
+    *.yaml merge=structdiff
+    *.toml merge=structdiff`,
+	Args: cobra.RangeArgs(3, 4),
+	Run:  runGitMergeDriver,
+}
+
+func runGitMergeDriver(cmd *cobra.Command, args []string) {
+	base, current, other := args[0], args[1], args[2]
+	path := current
+	if len(args) == 4 {
+		path = args[3]
+	}
+
+	var opts compare.Options
+	for _, spec := range listKeys {
+		key, err := compare.ParseListKey(spec)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+		opts.ListKeys = append(opts.ListKeys, key)
+	}
+
+	driverFormat := format
+	if driverFormat == "auto" {
+		detected, err := compare.DetectFormat(path)
+		if err != nil {
+			os.Exit(mergeLines(base, current, other, path, err))
+		}
+		driverFormat = detected
+	}
+
+	markers := compare.ConflictMarkers{Size: markerSize, Ours: "ours", Theirs: "theirs"}
+	merged, conflicts, err := compare.MergeFilesWithMarkers(base, current, other, driverFormat, opts, remoteConfig(), markers)
+	if err != nil {
+		os.Exit(mergeLines(base, current, other, path, err))
+	}
+
+	info, err := os.Stat(current)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing merge result: %v\n", err)
+		os.Exit(2)
+	}
+	if err := os.WriteFile(current, merged, info.Mode().Perm()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing merge result: %v\n", err)
+		os.Exit(2)
+	}
+
+	if len(conflicts) > 0 {
+		fmt.Fprintf(os.Stderr, "structdiff: %d conflicts in %s\n", len(conflicts), path)
+		for _, c := range conflicts {
+			fmt.Fprintf(os.Stderr, "  %s\n", c.Path)
+		}
+		os.Exit(1)
+	}
+}
+
+// mergeLines falls back to git's line-based merge and returns the exit
+// status to report to git.
+func mergeLines(base, current, other, path string, reason error) int {
+	fmt.Fprintf(os.Stderr, "structdiff: falling back to a line merge of %s: %v\n", path, reason)
+
+	mergeFile := exec.Command("git", "merge-file", "-L", "ours", "-L", "base", "-L", "theirs",
+		fmt.Sprintf("--marker-size=%d", markerSize), current, base, other)
+	mergeFile.Stderr = os.Stderr
+	err := mergeFile.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &exitErr) && exitErr.ExitCode() > 0 && exitErr.ExitCode() < 128:
+		// git merge-file exits with the number of conflicts.
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "Error merging %s: %v\n", path, err)
+	return 2
+}
+
+func init() {
+	gitMergeDriverCmd.Flags().StringVarP(&format, "format", "f", "auto", "Input format (json|yaml|toml|auto)")
+	gitMergeDriverCmd.Flags().IntVar(&markerSize, "marker-size", 7, "Length of conflict markers")
+	gitMergeDriverCmd.Flags().StringArrayVar(&listKeys, "list-key", nil, "Match list elements by key fields (path=field[,field])")
+	rootCmd.AddCommand(gitMergeDriverCmd)
+}
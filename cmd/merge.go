@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dolastack/structdiff/compare"
+	"github.com/dolastack/structdiff/output"
+	"github.com/spf13/cobra"
+)
+
+var (
+	mergeFormat    string
+	mergeOutput    string
+	mergeConflicts string
+)
+
+var mergeCmd = &cobra.Command{
+	Use:   "merge <base> <ours> <theirs>",
+	Short: "Three-way merge structured files",
+	Long: `Merge the changes made to base in ours and in theirs. Changes made on one
+side only are merged; overlapping changes are reported as conflicts by path
+and resolved in favour of ours. The merged document is written in the input
+format to stdout, or to --out, and the conflicts to stderr, or to
+--conflicts-file. Exits with status 1 when conflicts remain.`,
+	Args: cobra.ExactArgs(3),
+	Run:  runMerge,
+}
+
+func runMerge(cmd *cobra.Command, args []string) {
+	stdin := 0
+	for _, arg := range args {
+		if arg == "-" {
+			stdin++
+		}
+	}
+	if stdin > 1 {
+		fmt.Fprintln(os.Stderr, "Error: cannot read more than one file from stdin")
+		os.Exit(1)
+	}
+
+	var opts compare.Options
+	for _, spec := range listKeys {
+		key, err := compare.ParseListKey(spec)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		opts.ListKeys = append(opts.ListKeys, key)
+	}
+
+	config := remoteConfig()
+	var docs [3]*compare.Document
+	for i, source := range args {
+		doc, err := compare.FetchDocument(source, config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error merging files: %v\n", err)
+			os.Exit(1)
+		}
+		docs[i] = doc
+	}
+	if mergeFormat == "auto" {
+		detected, err := compare.DetectDocumentFormats(docs[1], docs[2])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error detecting format: %v\n", err)
+			os.Exit(1)
+		}
+		mergeFormat = detected.Format
+	}
+	for _, doc := range docs {
+		if err := doc.Parse(mergeFormat, config, compare.ParseOptions{}); err != nil {
+			fmt.Fprintf(os.Stderr, "Error merging files: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	merged, conflicts, err := compare.MergeDocuments(docs[0], docs[1], docs[2], opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error merging files: %v\n", err)
+		os.Exit(1)
+	}
+
+	var report string
+	switch outputFormat {
+	case "json":
+		report, err = output.FormatConflictsJSON(conflicts)
+	default:
+		report, err = output.FormatConflictsText(conflicts, color)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error formatting output: %v\n", err)
+		os.Exit(1)
+	}
+
+	if mergeOutput != "" {
+		err = os.WriteFile(mergeOutput, merged, 0644)
+	} else {
+		_, err = os.Stdout.Write(merged)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing merged document: %v\n", err)
+		os.Exit(1)
+	}
+
+	if mergeConflicts != "" {
+		err = os.WriteFile(mergeConflicts, []byte(report+"\n"), 0644)
+	} else if len(conflicts) > 0 || outputFormat == "json" {
+		_, err = fmt.Fprintln(os.Stderr, report)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing conflicts: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(conflicts) > 0 {
+		os.Exit(1)
+	}
+}
+
+func init() {
+	mergeCmd.Flags().StringVarP(&mergeFormat, "format", "f", "auto", "Input format (json|yaml|toml|auto)")
+	mergeCmd.Flags().StringVarP(&outputFormat, "output", "o", "text", "Conflict report format (text|json)")
+	mergeCmd.Flags().StringVar(&mergeOutput, "out", "", "Write the merged document to a file instead of stdout")
+	mergeCmd.Flags().StringVar(&mergeConflicts, "conflicts-file", "", "Write the conflict report to a file instead of stderr")
+	mergeCmd.Flags().StringArrayVar(&listKeys, "list-key", nil, "Match list elements by key fields (path=field[,field])")
+	mergeCmd.Flags().BoolVar(&color, "color", true, "Enable colored output")
+	addRemoteFlags(mergeCmd)
+	rootCmd.AddCommand(mergeCmd)
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/dolastack/structdiff/compare"
@@ -12,6 +13,8 @@ import (
 
 var (
 	format       string
+	firstFormat  string
+	secondFormat string
 	outputFormat string
 	color        bool
 	ignoreCase   bool
@@ -21,18 +24,42 @@ var (
 	username     string
 	password     string
 	token        string
+	listKeys     []string
+	csvKey       []string
+	unordered    bool
+	unorderedAt  []string
+	sortOrder    string
+	absTolerance float64
+	relTolerance float64
+	ignorePaths  []string
+	onlyPaths    []string
+	watch        bool
+	xmlOptions   compare.XMLOptions
+	csvOptions   compare.CSVOptions
+	csvDelimiter string
+	csvComment   string
 )
 
 var rootCmd = &cobra.Command{
-	Use:   "structdiff <file1> <file2>",
+	Use:   "structdiff <file1|dir1> <file2|dir2>",
 	Short: "Compare structured configuration files",
 	Long: `StructDiff compares configuration files in various formats and shows
-differences with automatic validation and helpful error messages.`,
-	Args: cobra.ExactArgs(2),
+differences with automatic validation and helpful error messages. Given two
+directories, it pairs their files by relative path and compares each pair.
+
+It can also be used as git's external diff program, in which case it is
+called with the seven arguments of GIT_EXTERNAL_DIFF:
+
+    GIT_EXTERNAL_DIFF=structdiff git diff`,
+	Args: checkArgs,
 	Run:  runComparison,
 }
 
 func runComparison(cmd *cobra.Command, args []string) {
+	if len(args) != 2 {
+		runExternalDiff(args)
+		return
+	}
 	file1, file2 := args[0], args[1]
 
 	// Check if both files are stdin
@@ -41,44 +68,221 @@ func runComparison(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	config := compare.RemoteConfig{
-		Timeout:      timeout,
-		MaxFileSize:  maxSize,
-		Username:     username,
-		Password:     password,
-		Token:        token,
-		SkipValidate: skipValidate,
+	config, parseOpts := remoteConfig(), parseOptions()
+	opts, err := comparisonOptions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
 
-	if format == "auto" {
-		detected, err := compare.DetectFormat(file1)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error detecting format: %v\n", err)
+	if dir1, dir2 := compare.IsDir(file1), compare.IsDir(file2); dir1 || dir2 {
+		if !dir1 || !dir2 {
+			fmt.Fprintln(os.Stderr, "Error: cannot compare a directory with a file")
+			os.Exit(1)
+		}
+		if watch {
+			fmt.Fprintln(os.Stderr, "Error: --watch is not supported for directories")
 			os.Exit(1)
 		}
-		format = detected
+		if firstFormat != "" || secondFormat != "" {
+			fmt.Fprintln(os.Stderr, "Error: --format1 and --format2 are not supported for directories")
+			os.Exit(1)
+		}
+		runDirComparison(file1, file2, opts, config, parseOpts)
+		return
 	}
 
-	diff, err := compare.CompareFiles(file1, file2, format, ignoreCase, config)
+	doc1, err := compare.FetchDocument(file1, config)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error comparing files: %v\n", err)
 		os.Exit(1)
 	}
+	doc2, err := compare.FetchDocument(file2, config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error comparing files: %v\n", err)
+		os.Exit(1)
+	}
+
+	format1, format2, err := resolveFormats(doc1, doc2)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error detecting format: %v\n", err)
+		os.Exit(1)
+	}
+
+	if watch {
+		runWatch(file1, format1, file2, format2, opts, config, parseOpts)
+		return
+	}
+
+	if err = doc1.Parse(format1, config, parseOpts); err == nil {
+		err = doc2.Parse(format2, config, parseOpts)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error comparing files: %v\n", err)
+		os.Exit(1)
+	}
+	diff, err := compare.CompareDocuments(doc1, doc2, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error comparing files: %v\n", err)
+		os.Exit(1)
+	}
+
+	result, err := formatDiffs(diff, doc1, doc2)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error formatting output: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println(result)
+}
+
+func runDirComparison(dir1, dir2 string, opts compare.Options, config compare.RemoteConfig, parseOpts compare.ParseOptions) {
+	files, err := compare.CompareDirs(dir1, dir2, format, opts, config, parseOpts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error comparing directories: %v\n", err)
+		os.Exit(1)
+	}
 
 	var result string
 	switch outputFormat {
 	case "json":
-		result, err = output.FormatJSON(diff)
+		result, err = output.FormatDirJSON(files)
+	case "text":
+		result, err = output.FormatDirText(files, color)
 	default:
-		result, err = output.FormatText(diff, color)
+		err = fmt.Errorf("%s output is not supported for directories", outputFormat)
 	}
-
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error formatting output: %v\n", err)
 		os.Exit(1)
 	}
 
 	fmt.Println(result)
+
+	for _, f := range files {
+		if f.Status == compare.FileError {
+			os.Exit(1)
+		}
+	}
+}
+
+// resolveFormats returns the formats of two fetched inputs. --format1 and
+// --format2 override --format for one side, and "auto" detects the format of
+// each side.
+func resolveFormats(doc1, doc2 *compare.Document) (string, string, error) {
+	formats := [2]string{format, format}
+	if firstFormat != "" {
+		formats[0] = firstFormat
+	}
+	if secondFormat != "" {
+		formats[1] = secondFormat
+	}
+
+	var detected [2]compare.Detection
+	var err error
+	switch {
+	case formats[0] == "auto" && formats[1] == "auto":
+		detected[0], detected[1], err = compare.DetectFormatPair(doc1, doc2)
+	case formats[0] == "auto":
+		if detected[0], err = compare.DetectDocumentFormat(doc1); err != nil {
+			detected[0], err = compare.Detection{Format: formats[1]}, nil
+		}
+	case formats[1] == "auto":
+		if detected[1], err = compare.DetectDocumentFormat(doc2); err != nil {
+			detected[1], err = compare.Detection{Format: formats[0]}, nil
+		}
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	for i, doc := range []*compare.Document{doc1, doc2} {
+		if formats[i] != "auto" {
+			continue
+		}
+		formats[i] = detected[i].Format
+		if detected[i].Method == "content" {
+			fmt.Fprintf(os.Stderr, "Detected format of %s: %s\n", doc.Source, detected[i])
+		}
+	}
+	return formats[0], formats[1], nil
+}
+
+// checkArgs accepts two files, or the arguments git passes to a
+// GIT_EXTERNAL_DIFF program: 7, or 9 for renames.
+func checkArgs(cmd *cobra.Command, args []string) error {
+	switch len(args) {
+	case 2, 7, 9:
+		return nil
+	}
+	return fmt.Errorf("accepts 2 files, or the 7 or 9 arguments of GIT_EXTERNAL_DIFF, received %d", len(args))
+}
+
+// comparisonOptions builds the comparison options from the command line.
+func comparisonOptions() (compare.Options, error) {
+	opts := compare.Options{
+		IgnoreCase:     ignoreCase,
+		CSVKey:         csvKey,
+		Unordered:      unordered,
+		UnorderedPaths: unorderedAt,
+		Ignore:         ignorePaths,
+		Only:           onlyPaths,
+		Order:          compare.SortOrder(sortOrder),
+		AbsTolerance:   absTolerance,
+		RelTolerance:   relTolerance,
+	}
+	if err := opts.Validate(); err != nil {
+		return opts, err
+	}
+	if opts.Order != compare.SortDocument && opts.Order != compare.SortPath {
+		return opts, fmt.Errorf("unsupported sort order: %s", sortOrder)
+	}
+	for _, spec := range listKeys {
+		key, err := compare.ParseListKey(spec)
+		if err != nil {
+			return opts, err
+		}
+		opts.ListKeys = append(opts.ListKeys, key)
+	}
+	return opts, nil
+}
+
+// formatDiffs renders differences in the selected output format. Patches
+// apply to the first document, and CSV differences are summarized by column.
+func formatDiffs(diff []compare.Diff, doc1, doc2 *compare.Document) (string, error) {
+	csv := strings.EqualFold(doc1.Format, "csv")
+	switch outputFormat {
+	case "json":
+		if csv {
+			return output.FormatCSVJSON(diff)
+		}
+		return output.FormatJSON(diff)
+	case "jsonpatch":
+		_, ops := compare.PatchBase(doc1, doc2)
+		return output.FormatPatchOperations(append(ops, compare.JSONPatch(diff)...))
+	case "mergepatch":
+		base, _ := compare.PatchBase(doc1, doc2)
+		return output.FormatMergePatch(diff, base)
+	}
+	if csv {
+		return output.FormatCSVText(diff, color)
+	}
+	return output.FormatText(diff, color)
+}
+
+// loadAndCompare loads and compares two files, returning both documents
+// along with the differences.
+func loadAndCompare(file1, format1, file2, format2 string, opts compare.Options, config compare.RemoteConfig, parseOpts compare.ParseOptions) ([]compare.Diff, *compare.Document, *compare.Document, error) {
+	doc1, err := compare.LoadDocument(file1, format1, config, parseOpts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	doc2, err := compare.LoadDocument(file2, format2, config, parseOpts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	diff, err := compare.CompareDocuments(doc1, doc2, opts)
+	return diff, doc1, doc2, err
 }
 
 func Execute() {
@@ -90,13 +294,81 @@ func Execute() {
 
 func init() {
 	rootCmd.Flags().StringVarP(&format, "format", "f", "auto", "Input format (json|yaml|toml|xml|ini|csv|hcl|auto)")
-	rootCmd.Flags().StringVarP(&outputFormat, "output", "o", "text", "Output format (text|json)")
+	rootCmd.Flags().StringVar(&firstFormat, "format1", "", "Format of the first file, overriding --format")
+	rootCmd.Flags().StringVar(&secondFormat, "format2", "", "Format of the second file, overriding --format")
+	rootCmd.Flags().StringVarP(&outputFormat, "output", "o", "text", "Output format (text|json|jsonpatch|mergepatch)")
 	rootCmd.Flags().BoolVar(&color, "color", true, "Enable colored output")
 	rootCmd.Flags().BoolVarP(&ignoreCase, "ignore-case", "i", false, "Ignore case differences")
-	rootCmd.Flags().BoolVar(&skipValidate, "skip-validate", false, "Skip file validation")
-	rootCmd.Flags().DurationVar(&timeout, "timeout", 30*time.Second, "Request timeout")
-	rootCmd.Flags().Int64Var(&maxSize, "max-size", 10*1024*1024, "Max file size in bytes")
-	rootCmd.Flags().StringVar(&username, "username", "", "Basic auth username")
-	rootCmd.Flags().StringVar(&password, "password", "", "Basic auth password")
-	rootCmd.Flags().StringVar(&token, "token", "", "Bearer token")
+	rootCmd.Flags().StringArrayVar(&listKeys, "list-key", nil, "Match list elements by key fields (path=field[,field], e.g. spec.containers[*]=name)")
+	rootCmd.Flags().StringSliceVar(&csvKey, "csv-key", nil, "Match CSV rows by key columns, ignoring row order (e.g. id, or id,region for a composite key)")
+	rootCmd.Flags().BoolVar(&unordered, "unordered", false, "Compare all lists as unordered multisets")
+	rootCmd.Flags().StringArrayVar(&unorderedAt, "unordered-path", nil, "Compare lists matching a path pattern as unordered multisets")
+	rootCmd.Flags().StringArrayVar(&ignorePaths, "ignore", nil, "Ignore paths matching a pattern (e.g. metadata.annotations.*, items[*].status)")
+	rootCmd.Flags().StringArrayVar(&onlyPaths, "only", nil, "Only compare paths matching a pattern")
+	rootCmd.Flags().StringVar(&sortOrder, "sort", "document", "Order of reported differences (document|path)")
+	rootCmd.Flags().Float64Var(&absTolerance, "abs-tolerance", 0, "Treat numbers within this absolute difference as equal")
+	rootCmd.Flags().Float64Var(&relTolerance, "rel-tolerance", 0, "Treat numbers within this relative difference as equal")
+	rootCmd.Flags().BoolVar(&watch, "watch", false, "Keep running and re-render the diff whenever either file changes")
+	addRemoteFlags(rootCmd)
+	addParseFlags(rootCmd)
+}
+
+// addRemoteFlags registers the flags controlling how files are fetched.
+func addRemoteFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&skipValidate, "skip-validate", false, "Skip file validation")
+	cmd.Flags().DurationVar(&timeout, "timeout", 30*time.Second, "Request timeout")
+	cmd.Flags().Int64Var(&maxSize, "max-size", 10*1024*1024, "Max file size in bytes")
+	cmd.Flags().StringVar(&username, "username", "", "Basic auth username")
+	cmd.Flags().StringVar(&password, "password", "", "Basic auth password")
+	cmd.Flags().StringVar(&token, "token", "", "Bearer token")
+}
+
+// addParseFlags registers the flags controlling how documents are parsed.
+func addParseFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&xmlOptions.IgnoreNamespaces, "xml-ignore-namespaces", false, "Compare XML names without their namespace")
+	cmd.Flags().BoolVar(&xmlOptions.IgnoreComments, "xml-ignore-comments", false, "Ignore XML comments")
+	cmd.Flags().BoolVar(&xmlOptions.IgnoreProcessingInstructions, "xml-ignore-pi", false, "Ignore XML processing instructions")
+	cmd.Flags().BoolVar(&xmlOptions.IgnoreWhitespace, "xml-ignore-whitespace", false, "Trim and collapse whitespace in XML text")
+	cmd.Flags().StringVar(&csvDelimiter, "csv-delimiter", "", `CSV field delimiter, e.g. ";" or "\t" (default "," or a tab for .tsv files)`)
+	cmd.Flags().BoolVar(&csvOptions.NoHeader, "csv-no-header", false, "Treat the first CSV record as data and key cells by column index")
+	cmd.Flags().StringVar(&csvComment, "csv-comment", "", `Ignore CSV lines starting with this character, e.g. "#"`)
+	cmd.Flags().BoolVar(&csvOptions.LazyQuotes, "csv-lazy-quotes", false, "Accept stray quotes in CSV fields")
+	cmd.Flags().BoolVar(&csvOptions.Ragged, "csv-ragged", false, "Accept CSV records with varying numbers of fields")
+	cmd.Flags().BoolVar(&csvOptions.InferTypes, "csv-infer-types", false, "Infer integer, float, boolean and date CSV columns so that e.g. 1 and 1.0 are equal")
+}
+
+// parseCSVRune parses the value of a flag naming a single CSV character,
+// accepting "\t" for a tab.
+func parseCSVRune(flag, value string) rune {
+	if value == `\t` || value == "tab" {
+		return '\t'
+	}
+	runes := []rune(value)
+	switch len(runes) {
+	case 0:
+		return 0
+	case 1:
+		return runes[0]
+	}
+	fmt.Fprintf(os.Stderr, "Error: --%s must be a single character, got %q\n", flag, value)
+	os.Exit(1)
+	return 0
+}
+
+func remoteConfig() compare.RemoteConfig {
+	return compare.RemoteConfig{
+		Timeout:      timeout,
+		MaxFileSize:  maxSize,
+		Username:     username,
+		Password:     password,
+		Token:        token,
+		SkipValidate: skipValidate,
+	}
+}
+
+func parseOptions() compare.ParseOptions {
+	csv := csvOptions
+	csv.Delimiter = parseCSVRune("csv-delimiter", csvDelimiter)
+	csv.Comment = parseCSVRune("csv-comment", csvComment)
+	return compare.ParseOptions{XML: xmlOptions, CSV: csv}
 }
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dolastack/structdiff/compare"
+)
+
+// watchDebounce is how long to wait for a burst of changes, such as an editor
+// saving through a temporary file, to settle before re-rendering.
+const watchDebounce = 100 * time.Millisecond
+
+// fileWatcher reports changes to a set of files.
+type fileWatcher interface {
+	// Wait blocks until one of the files changes.
+	Wait() error
+}
+
+func runWatch(file1, format1, file2, format2 string, opts compare.Options, config compare.RemoteConfig, parseOpts compare.ParseOptions) {
+	for _, file := range []string{file1, file2} {
+		if file == "-" || strings.HasPrefix(file, "https://") || strings.HasPrefix(file, "git:") {
+			fmt.Fprintf(os.Stderr, "Error: --watch requires local files, got %s\n", file)
+			os.Exit(1)
+		}
+	}
+
+	watcher, err := newFileWatcher([]string{file1, file2})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error watching files: %v\n", err)
+		os.Exit(1)
+	}
+
+	for {
+		// Clear the screen and move the cursor home.
+		fmt.Print("\033[H\033[2J")
+		fmt.Printf("Watching %s and %s (%s)\n\n", file1, file2, time.Now().Format("15:04:05"))
+
+		diff, doc1, doc2, err := loadAndCompare(file1, format1, file2, format2, opts, config, parseOpts)
+		if err != nil {
+			fmt.Printf("Error comparing files: %v\n", err)
+		} else if result, err := formatDiffs(diff, doc1, doc2); err != nil {
+			fmt.Printf("Error formatting output: %v\n", err)
+		} else {
+			fmt.Println(result)
+		}
+
+		if err := watcher.Wait(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error watching files: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+//go:build linux
+
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// inotifyWatcher watches the directories containing the files rather than
+// the files themselves, so that changes are still seen after an editor
+// replaces a file by renaming another over it.
+type inotifyWatcher struct {
+	fd     int
+	names  map[int]map[string]bool // base names of interest by watch descriptor
+	events chan struct{}
+	errs   chan error
+}
+
+func newFileWatcher(files []string) (fileWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &inotifyWatcher{
+		fd:     fd,
+		names:  make(map[int]map[string]bool),
+		events: make(chan struct{}, 1),
+		errs:   make(chan error, 1),
+	}
+	const mask = syscall.IN_CLOSE_WRITE | syscall.IN_MODIFY | syscall.IN_CREATE |
+		syscall.IN_MOVED_TO | syscall.IN_DELETE
+	for _, file := range files {
+		wd, err := syscall.InotifyAddWatch(fd, filepath.Dir(file), mask)
+		if err != nil {
+			syscall.Close(fd)
+			return nil, err
+		}
+		if w.names[wd] == nil {
+			w.names[wd] = make(map[string]bool)
+		}
+		w.names[wd][filepath.Base(file)] = true
+	}
+
+	go w.read()
+	return w, nil
+}
+
+func (w *inotifyWatcher) read() {
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := syscall.Read(w.fd, buf)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			w.errs <- err
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			name := string(bytes.TrimRight(buf[nameStart:nameStart+int(event.Len)], "\x00"))
+			offset = nameStart + int(event.Len)
+
+			if w.names[int(event.Wd)][name] {
+				select {
+				case w.events <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}
+}
+
+func (w *inotifyWatcher) Wait() error {
+	select {
+	case <-w.events:
+	case err := <-w.errs:
+		return err
+	}
+
+	// Swallow the rest of a burst of changes.
+	timer := time.NewTimer(watchDebounce)
+	for {
+		select {
+		case <-w.events:
+			timer.Reset(watchDebounce)
+		case <-timer.C:
+			return nil
+		case err := <-w.errs:
+			return err
+		}
+	}
+}
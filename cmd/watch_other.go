@@ -0,0 +1,56 @@
+//go:build !linux
+
+package cmd
+
+import (
+	"os"
+	"time"
+)
+
+// pollInterval is how often files are checked where inotify is unavailable.
+const pollInterval = 500 * time.Millisecond
+
+// pollWatcher detects changes by comparing the size and modification time of
+// the files.
+type pollWatcher struct {
+	files []string
+	state []fileState
+}
+
+type fileState struct {
+	size    int64
+	modTime time.Time
+	exists  bool
+}
+
+func newFileWatcher(files []string) (fileWatcher, error) {
+	w := &pollWatcher{files: files}
+	w.state = w.snapshot()
+	return w, nil
+}
+
+func (w *pollWatcher) snapshot() []fileState {
+	state := make([]fileState, len(w.files))
+	for i, file := range w.files {
+		if info, err := os.Stat(file); err == nil {
+			state[i] = fileState{size: info.Size(), modTime: info.ModTime(), exists: true}
+		}
+	}
+	return state
+}
+
+func (w *pollWatcher) Wait() error {
+	for {
+		time.Sleep(pollInterval)
+		state := w.snapshot()
+		for i := range state {
+			if state[i] != w.state[i] {
+				w.state = state
+				// Let a burst of changes settle.
+				time.Sleep(watchDebounce)
+				w.state = w.snapshot()
+				return nil
+			}
+		}
+	}
+}
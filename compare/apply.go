@@ -0,0 +1,137 @@
+package compare
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ApplyFile applies the patch read from patchSource to the document read from
+// source and returns the result encoded in the document's format, which must
+// be json, yaml or toml. The patch may be a structdiff JSON diff, an RFC 6902
+// JSON Patch or an RFC 7386 JSON Merge Patch.
+func ApplyFile(source, patchSource, format string, config RemoteConfig) ([]byte, error) {
+	doc, err := LoadDocument(source, format, config, ParseOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return ApplyDocument(doc, patchSource, config)
+}
+
+// ApplyDocument is ApplyFile for a parsed document.
+func ApplyDocument(doc *Document, patchSource string, config RemoteConfig) ([]byte, error) {
+	patch, err := readFileContent(patchSource, config)
+	if err != nil {
+		return nil, fmt.Errorf("error reading patch: %w", err)
+	}
+	result, order, err := applyPatchDocument(doc.Tree, doc.order, patch)
+	if err != nil {
+		return nil, err
+	}
+	return encodeDocument(result, doc.Format, order)
+}
+
+// ApplyPatchDocument applies an encoded patch to doc, recognizing the patch
+// kind from its shape: a JSON array is a JSON Patch, an object with "summary"
+// and "diffs" members is a structdiff JSON diff, even when diffs is null, and
+// any other object is a JSON Merge Patch.
+func ApplyPatchDocument(doc interface{}, patch []byte) (interface{}, error) {
+	doc, _, err := applyPatchDocument(doc, nil, patch)
+	return doc, err
+}
+
+// applyPatchDocument applies a patch to doc and its key order. The values
+// carried by the patch keep the key order they were given in.
+func applyPatchDocument(doc interface{}, order *keyOrder, patch []byte) (interface{}, *keyOrder, error) {
+	decoded, patchOrder, err := decodeJSON(patch)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing patch: %w", err)
+	}
+
+	switch p := decoded.(type) {
+	case []interface{}:
+		var ops []PatchOperation
+		if err := unmarshalNumbers(patch, &ops); err != nil {
+			return nil, nil, fmt.Errorf("error parsing JSON Patch: %w", err)
+		}
+		for i := range ops {
+			ops[i].Value = member(p[i], "value")
+			ops[i].order = patchOrder.elem(i).field("value")
+		}
+		return applyPatch(doc, order, ops)
+	case map[string]interface{}:
+		_, hasSummary := p["summary"]
+		rawDiffs, hasDiffs := p["diffs"]
+		if hasSummary && hasDiffs {
+			// Identical inputs may be written with "diffs": null.
+			diffList, ok := rawDiffs.([]interface{})
+			if !ok && rawDiffs != nil {
+				return nil, nil, errors.New("error parsing structdiff diff: diffs must be a list")
+			}
+			var result struct {
+				Diffs []Diff `json:"diffs"`
+			}
+			if err := unmarshalNumbers(patch, &result); err != nil {
+				return nil, nil, fmt.Errorf("error parsing structdiff diff: %w", err)
+			}
+			for i := range result.Diffs {
+				result.Diffs[i].OldValue = member(diffList[i], "old_value")
+				result.Diffs[i].NewValue = member(diffList[i], "new_value")
+				result.Diffs[i].order = patchOrder.field("diffs").elem(i).field("new_value")
+			}
+			return applyPatch(doc, order, JSONPatch(result.Diffs))
+		}
+		doc, order := applyMergePatch(doc, order, p, patchOrder)
+		return doc, order, nil
+	}
+	return nil, nil, errors.New("patch must be a JSON array or object")
+}
+
+func member(v interface{}, key string) interface{} {
+	if m, ok := v.(map[string]interface{}); ok {
+		return m[key]
+	}
+	return nil
+}
+
+// ApplyMergePatch applies an RFC 7386 JSON Merge Patch to doc.
+func ApplyMergePatch(doc, patch interface{}) interface{} {
+	doc, _ = applyMergePatch(doc, nil, patch, nil)
+	return doc
+}
+
+// applyMergePatch applies a merge patch to doc and its key order. Keys added
+// by the patch follow the existing ones in the order of the patch.
+func applyMergePatch(doc interface{}, order *keyOrder, patch interface{}, patchOrder *keyOrder) (interface{}, *keyOrder) {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch, patchOrder
+	}
+	target, ok := doc.(map[string]interface{})
+	if !ok {
+		target, order = make(map[string]interface{}), nil
+	}
+	order = order.mutable()
+	for _, key := range patchOrder.keys(p) {
+		value := p[key]
+		if value == nil {
+			delete(target, key)
+			order.removeName(key)
+			continue
+		}
+		if _, exists := target[key]; !exists {
+			order.addName(key)
+		}
+		var child *keyOrder
+		target[key], child = applyMergePatch(target[key], order.field(key), value, patchOrder.field(key))
+		order.setField(key, child)
+	}
+	return target, order
+}
+
+func unmarshalNumbers(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
@@ -0,0 +1,267 @@
+package compare
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// BatchManifest lists the file pairs compared by a batch run:
+//
+//	workers: 4
+//	pairs:
+//	  - name: web
+//	    file1: rendered/web.yaml
+//	    file2: committed/web.yaml
+//	    list_keys: ["spec.containers=name"]
+//	    ignore: ["metadata.annotations.*"]
+type BatchManifest struct {
+	// Workers bounds the number of concurrent comparisons; zero means one
+	// per CPU.
+	Workers int         `yaml:"workers"`
+	Pairs   []BatchPair `yaml:"pairs"`
+}
+
+// BatchPair is one comparison of a batch manifest. The remaining fields
+// mirror Options and ParseOptions; Format is detected from both files when
+// empty.
+type BatchPair struct {
+	Name           string   `yaml:"name"`
+	File1          string   `yaml:"file1"`
+	File2          string   `yaml:"file2"`
+	Format         string   `yaml:"format"`
+	IgnoreCase     bool     `yaml:"ignore_case"`
+	ListKeys       []string `yaml:"list_keys"`
+	CSVKey         []string `yaml:"csv_key"`
+	Unordered      bool     `yaml:"unordered"`
+	UnorderedPaths []string `yaml:"unordered_paths"`
+	Ignore         []string `yaml:"ignore"`
+	Only           []string `yaml:"only"`
+	Sort           string   `yaml:"sort"`
+	AbsTolerance   float64  `yaml:"abs_tolerance"`
+	RelTolerance   float64  `yaml:"rel_tolerance"`
+
+	XMLIgnoreNamespaces bool   `yaml:"xml_ignore_namespaces"`
+	XMLIgnoreComments   bool   `yaml:"xml_ignore_comments"`
+	XMLIgnorePI         bool   `yaml:"xml_ignore_pi"`
+	XMLIgnoreWhitespace bool   `yaml:"xml_ignore_whitespace"`
+	CSVDelimiter        string `yaml:"csv_delimiter"`
+	CSVNoHeader         bool   `yaml:"csv_no_header"`
+	CSVComment          string `yaml:"csv_comment"`
+	CSVLazyQuotes       bool   `yaml:"csv_lazy_quotes"`
+	CSVRagged           bool   `yaml:"csv_ragged"`
+	CSVInferTypes       bool   `yaml:"csv_infer_types"`
+}
+
+// Options returns the comparison options of the pair.
+func (p BatchPair) Options() (Options, error) {
+	opts := Options{
+		IgnoreCase:     p.IgnoreCase,
+		CSVKey:         p.CSVKey,
+		Unordered:      p.Unordered,
+		UnorderedPaths: p.UnorderedPaths,
+		Ignore:         p.Ignore,
+		Only:           p.Only,
+		Order:          SortOrder(p.Sort),
+		AbsTolerance:   p.AbsTolerance,
+		RelTolerance:   p.RelTolerance,
+	}
+	if opts.Order == "" {
+		opts.Order = SortDocument
+	}
+	if opts.Order != SortDocument && opts.Order != SortPath {
+		return opts, fmt.Errorf("unsupported sort order: %s", p.Sort)
+	}
+	for _, spec := range p.ListKeys {
+		key, err := ParseListKey(spec)
+		if err != nil {
+			return opts, err
+		}
+		opts.ListKeys = append(opts.ListKeys, key)
+	}
+	return opts, opts.Validate()
+}
+
+// ParseOptions returns the parse options of the pair: those set on the pair
+// are added to defaults, and its delimiter and comment character replace
+// theirs when given.
+func (p BatchPair) ParseOptions(defaults ParseOptions) (ParseOptions, error) {
+	opts := defaults
+	opts.XML.IgnoreNamespaces = opts.XML.IgnoreNamespaces || p.XMLIgnoreNamespaces
+	opts.XML.IgnoreComments = opts.XML.IgnoreComments || p.XMLIgnoreComments
+	opts.XML.IgnoreProcessingInstructions = opts.XML.IgnoreProcessingInstructions || p.XMLIgnorePI
+	opts.XML.IgnoreWhitespace = opts.XML.IgnoreWhitespace || p.XMLIgnoreWhitespace
+	opts.CSV.NoHeader = opts.CSV.NoHeader || p.CSVNoHeader
+	opts.CSV.LazyQuotes = opts.CSV.LazyQuotes || p.CSVLazyQuotes
+	opts.CSV.Ragged = opts.CSV.Ragged || p.CSVRagged
+	opts.CSV.InferTypes = opts.CSV.InferTypes || p.CSVInferTypes
+	for _, field := range []struct {
+		name   string
+		value  string
+		target *rune
+	}{
+		{"csv_delimiter", p.CSVDelimiter, &opts.CSV.Delimiter},
+		{"csv_comment", p.CSVComment, &opts.CSV.Comment},
+	} {
+		if field.value == "" {
+			continue
+		}
+		if field.value == `\t` || field.value == "tab" {
+			*field.target = '\t'
+			continue
+		}
+		runes := []rune(field.value)
+		if len(runes) != 1 {
+			return opts, fmt.Errorf("%s must be a single character, got %q", field.name, field.value)
+		}
+		*field.target = runes[0]
+	}
+	return opts, nil
+}
+
+// BatchResult is the outcome of comparing one pair: FileUnchanged,
+// FileModified with the differences found, or FileError.
+type BatchResult struct {
+	Name   string     `json:"name"`
+	File1  string     `json:"file1"`
+	File2  string     `json:"file2"`
+	Format string     `json:"format,omitempty"`
+	Status FileStatus `json:"status"`
+	Diffs  []Diff     `json:"diffs,omitempty"`
+	Error  string     `json:"error,omitempty"`
+}
+
+// LoadBatchManifest reads a YAML batch manifest. Relative local paths in it
+// are resolved against the manifest's directory, and pairs without a name are
+// named after their files.
+func LoadBatchManifest(source string, config RemoteConfig) (*BatchManifest, error) {
+	data, err := readFileContent(source, config)
+	if err != nil {
+		return nil, fmt.Errorf("error reading manifest: %w", err)
+	}
+
+	var manifest BatchManifest
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("error parsing manifest: %w", err)
+	}
+
+	dir := "."
+	if source != "-" && !isRemoteSource(source) {
+		dir = filepath.Dir(source)
+	}
+	for i := range manifest.Pairs {
+		pair := &manifest.Pairs[i]
+		if pair.File1 == "" || pair.File2 == "" {
+			return nil, fmt.Errorf("manifest pair %d: file1 and file2 are required", i+1)
+		}
+		if pair.File1 == "-" || pair.File2 == "-" {
+			return nil, fmt.Errorf("manifest pair %d: cannot read from stdin", i+1)
+		}
+		if pair.Name == "" {
+			pair.Name = pair.File1 + " ↔ " + pair.File2
+		}
+		pair.File1 = resolveSource(dir, pair.File1)
+		pair.File2 = resolveSource(dir, pair.File2)
+	}
+	return &manifest, nil
+}
+
+func isRemoteSource(source string) bool {
+	return strings.HasPrefix(source, "https://") || strings.HasPrefix(source, gitScheme)
+}
+
+func resolveSource(dir, source string) string {
+	if source == "-" || isRemoteSource(source) || filepath.IsAbs(source) {
+		return source
+	}
+	return filepath.Join(dir, source)
+}
+
+// CompareBatch compares every pair, running at most workers comparisons at a
+// time, and returns the results in pair order. Documents are parsed with
+// parseOpts combined with the parse options of their pair (see
+// BatchPair.ParseOptions). Files shared by several pairs are read and parsed
+// once for each set of parse options.
+func CompareBatch(pairs []BatchPair, workers int, config RemoteConfig, parseOpts ParseOptions) []BatchResult {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	cache := NewDocumentCache(config)
+	results := make([]BatchResult, len(pairs))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(pairs); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = compareBatchPair(pairs[i], cache, parseOpts)
+			}
+		}()
+	}
+	for i := range pairs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+func compareBatchPair(pair BatchPair, cache *DocumentCache, parseOpts ParseOptions) BatchResult {
+	result := BatchResult{Name: pair.Name, File1: pair.File1, File2: pair.File2, Format: pair.Format}
+	fail := func(err error) BatchResult {
+		result.Status, result.Error = FileError, err.Error()
+		return result
+	}
+
+	opts, err := pair.Options()
+	if err != nil {
+		return fail(err)
+	}
+	parseOpts, err = pair.ParseOptions(parseOpts)
+	if err != nil {
+		return fail(err)
+	}
+	if result.Format == "" || result.Format == "auto" {
+		fetched1, err := cache.Fetch(pair.File1)
+		if err != nil {
+			return fail(err)
+		}
+		fetched2, err := cache.Fetch(pair.File2)
+		if err != nil {
+			return fail(err)
+		}
+		detected, err := DetectDocumentFormats(fetched1, fetched2)
+		if err != nil {
+			return fail(err)
+		}
+		result.Format = detected.Format
+	}
+
+	doc1, err := cache.Load(pair.File1, result.Format, parseOpts)
+	if err != nil {
+		return fail(err)
+	}
+	doc2, err := cache.Load(pair.File2, result.Format, parseOpts)
+	if err != nil {
+		return fail(err)
+	}
+	diffs, err := CompareDocuments(doc1, doc2, opts)
+	switch {
+	case err != nil:
+		return fail(err)
+	case len(diffs) > 0:
+		result.Status, result.Diffs = FileModified, diffs
+	default:
+		result.Status = FileUnchanged
+	}
+	return result
+}
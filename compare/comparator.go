@@ -25,14 +25,40 @@ var DefaultRemoteConfig = RemoteConfig{
 	MaxFileSize: 10 * 1024 * 1024,
 }
 
+// ParseOptions controls how XML and CSV documents are parsed. The zero value
+// parses them with the defaults.
+type ParseOptions struct {
+	XML XMLOptions
+	CSV CSVOptions
+}
+
+// Comparator compares documents parsed in its format.
 type Comparator interface {
-	Compare(file1, file2 string, ignoreCase bool, config RemoteConfig) ([]Diff, error)
+	Compare(doc1, doc2 *Document, opts Options) ([]Diff, error)
 	Validator() FileValidator
 }
 
+// documentParser is implemented by every comparator to decode a single
+// document along with the source order of its keys.
+type documentParser interface {
+	parse(data []byte, name string) (interface{}, *keyOrder, error)
+}
+
 func readFileContent(source string, config RemoteConfig) ([]byte, error) {
+	data, _, err := fetchSource(source, config)
+	return data, err
+}
+
+// fetchSource reads a source, also returning the media type reported by the
+// server for remote files.
+func fetchSource(source string, config RemoteConfig) ([]byte, string, error) {
 	if source == "-" {
-		return io.ReadAll(os.Stdin)
+		data, err := readStdin()
+		return data, "", err
+	}
+	if strings.HasPrefix(source, gitScheme) {
+		data, err := readGitBlob(source, config)
+		return data, "", err
 	}
 	if strings.HasPrefix(source, "https://") {
 		client := &http.Client{
@@ -41,7 +67,7 @@ func readFileContent(source string, config RemoteConfig) ([]byte, error) {
 
 		req, err := http.NewRequest("GET", source, nil)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create request: %v", err)
+			return nil, "", fmt.Errorf("failed to create request: %v", err)
 		}
 
 		if config.Username != "" && config.Password != "" {
@@ -52,116 +78,115 @@ func readFileContent(source string, config RemoteConfig) ([]byte, error) {
 
 		resp, err := client.Do(req)
 		if err != nil {
-			return nil, fmt.Errorf("failed to fetch remote file: %v", err)
+			return nil, "", fmt.Errorf("failed to fetch remote file: %v", err)
 		}
 		defer resp.Body.Close()
 
 		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("remote server returned status: %d", resp.StatusCode)
+			return nil, "", fmt.Errorf("remote server returned status: %d", resp.StatusCode)
 		}
 
 		if config.MaxFileSize > 0 && resp.ContentLength > config.MaxFileSize {
-			return nil, fmt.Errorf("file size %d exceeds limit of %d bytes",
+			return nil, "", fmt.Errorf("file size %d exceeds limit of %d bytes",
 				resp.ContentLength, config.MaxFileSize)
 		}
 
 		reader := io.LimitReader(resp.Body, config.MaxFileSize)
 		content, err := io.ReadAll(reader)
 		if err != nil {
-			return nil, fmt.Errorf("error reading remote content: %v", err)
+			return nil, "", fmt.Errorf("error reading remote content: %v", err)
 		}
 
 		if config.MaxFileSize > 0 && int64(len(content)) == config.MaxFileSize {
 			_, err := io.CopyN(io.Discard, resp.Body, 1)
 			if err == nil {
-				return nil, fmt.Errorf("file size exceeds limit of %d bytes", config.MaxFileSize)
+				return nil, "", fmt.Errorf("file size exceeds limit of %d bytes", config.MaxFileSize)
 			}
 		}
 
-		return content, nil
+		return content, resp.Header.Get("Content-Type"), nil
 	}
 
 	if config.MaxFileSize > 0 {
 		fileInfo, err := os.Stat(source)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 		if fileInfo.Size() > config.MaxFileSize {
-			return nil, fmt.Errorf("file size %d exceeds limit of %d bytes",
+			return nil, "", fmt.Errorf("file size %d exceeds limit of %d bytes",
 				fileInfo.Size(), config.MaxFileSize)
 		}
 	}
 
-	return os.ReadFile(source)
+	data, err := os.ReadFile(source)
+	return data, "", err
 }
 
-func DetectFormat(filename string) (string, error) {
-	ext := strings.ToLower(filepath.Ext(filename))
-	switch ext {
-	case ".json":
-		return "json", nil
-	case ".yaml", ".yml":
-		return "yaml", nil
-	case ".toml":
-		return "toml", nil
-	case ".xml":
-		return "xml", nil
-	case ".ini", ".cfg":
-		return "ini", nil
-	case ".csv":
-		return "csv", nil
-	case ".hcl":
-		return "hcl", nil
-	case ".hcl.json", ".json.hcl":
-		return "hcljson", nil
-	default:
-		return "", fmt.Errorf("unsupported file format: %s", ext)
-	}
-}
-
-func CompareFiles(file1, file2, format string, ignoreCase bool, config RemoteConfig) ([]Diff, error) {
-	var comparator Comparator
-
+func newComparator(format string) (Comparator, error) {
 	switch strings.ToLower(format) {
 	case "json":
-		comparator = &JSONComparator{}
+		return &JSONComparator{}, nil
 	case "yaml":
-		comparator = &YAMLComparator{}
+		return &YAMLComparator{}, nil
 	case "toml":
-		comparator = &TOMLComparator{}
+		return &TOMLComparator{}, nil
 	case "xml":
-		comparator = &XMLComparator{}
+		return &XMLComparator{}, nil
 	case "ini":
-		comparator = &INIComparator{}
+		return &INIComparator{}, nil
 	case "csv":
-		comparator = &CSVComparator{}
+		return &CSVComparator{}, nil
 	case "hcl":
-		comparator = &HCLComparator{}
+		return &HCLComparator{}, nil
 	case "hcljson":
-		comparator = &HCLJSONComparator{}
+		return &HCLJSONComparator{}, nil
 	default:
 		return nil, errors.New("unsupported format")
 	}
+}
 
-	if !config.SkipValidate && comparator.Validator() != nil {
-		data1, err := readFileContent(file1, config)
-		if err != nil {
-			return nil, fmt.Errorf("error reading first file: %w", err)
-		}
-		if err := comparator.Validator().Validate(data1); err != nil {
-			return nil, fmt.Errorf("first file validation failed: %w\n%s",
-				err, comparator.Validator().ValidationHelp())
+// newParser returns the comparator for format, configured to parse source as
+// parseOpts asks.
+func newParser(format, source string, parseOpts ParseOptions) (Comparator, error) {
+	comparator, err := newComparator(format)
+	if err != nil {
+		return nil, err
+	}
+	switch p := comparator.(type) {
+	case *XMLComparator:
+		p.Options = parseOpts.XML
+	case *CSVComparator:
+		p.Options = parseOpts.CSV
+		if p.Options.Delimiter == 0 && filepath.Ext(untemplatedName(sourceName(source))) == ".tsv" {
+			p.Options.Delimiter = '\t'
 		}
+	}
+	return comparator, nil
+}
 
-		data2, err := readFileContent(file2, config)
-		if err != nil {
-			return nil, fmt.Errorf("error reading second file: %w", err)
-		}
-		if err := comparator.Validator().Validate(data2); err != nil {
-			return nil, fmt.Errorf("second file validation failed: %w\n%s",
-				err, comparator.Validator().ValidationHelp())
-		}
+func CompareFiles(file1, file2, format string, opts Options, config RemoteConfig, parseOpts ParseOptions) ([]Diff, error) {
+	return CompareFilesAs(file1, format, file2, format, opts, config, parseOpts)
+}
+
+// CompareFilesAs compares two files that may be in different formats, e.g. a
+// YAML source with the JSON rendered from it. When the formats differ, both
+// documents are normalized (see normalizeTree) and strings are compared with
+// numbers and booleans by value, as if opts.CoerceScalars were set.
+func CompareFilesAs(file1, format1, file2, format2 string, opts Options, config RemoteConfig, parseOpts ParseOptions) ([]Diff, error) {
+	if _, err := newComparator(format1); err != nil {
+		return nil, err
+	}
+	if _, err := newComparator(format2); err != nil {
+		return nil, err
 	}
 
-	return comparator.Compare(file1, file2, ignoreCase, config)
+	doc1, err := LoadDocument(file1, format1, config, parseOpts)
+	if err != nil {
+		return nil, err
+	}
+	doc2, err := LoadDocument(file2, format2, config, parseOpts)
+	if err != nil {
+		return nil, err
+	}
+	return CompareDocuments(doc1, doc2, opts)
 }
@@ -1,8 +1,11 @@
 package compare
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 )
 
@@ -17,9 +20,103 @@ const (
 
 type Diff struct {
 	Type     DiffType    `json:"type"`
-	Path     string      `json:"path"`
+	Path     Path        `json:"path"`
 	OldValue interface{} `json:"old_value,omitempty"`
 	NewValue interface{} `json:"new_value,omitempty"`
+	From     Path        `json:"from,omitempty"`
+
+	// order records the key order of NewValue, so patches built from the
+	// difference keep it.
+	order *keyOrder
+}
+
+// MarshalJSON adds RFC 6901 JSON Pointer renderings of the paths for machine
+// consumers.
+func (d Diff) MarshalJSON() ([]byte, error) {
+	type diff Diff
+	out := struct {
+		diff
+		Pointer     string `json:"pointer"`
+		FromPointer string `json:"from_pointer,omitempty"`
+	}{diff: diff(d), Pointer: d.Path.Pointer()}
+	if d.From != nil {
+		out.FromPointer = d.From.Pointer()
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON reads the output of MarshalJSON. List indexes are taken from
+// the JSON Pointers when present, since elements matched by key fields are
+// rendered by their selector in the human path.
+func (d *Diff) UnmarshalJSON(data []byte) error {
+	type diff Diff
+	var in struct {
+		diff
+		Pointer     string `json:"pointer"`
+		FromPointer string `json:"from_pointer"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&in); err != nil {
+		return err
+	}
+	*d = Diff(in.diff)
+	if err := fillIndexes(d.Path, in.Pointer); err != nil {
+		return err
+	}
+	return fillIndexes(d.From, in.FromPointer)
+}
+
+func fillIndexes(path Path, pointer string) error {
+	if pointer == "" {
+		return nil
+	}
+	tokens, err := ParsePointer(pointer)
+	if err != nil {
+		return err
+	}
+	if len(tokens) != len(path) {
+		return fmt.Errorf("pointer %q does not match path %q", pointer, path)
+	}
+	for i := range path {
+		if path[i].Element {
+			index, err := strconv.Atoi(tokens[i].Key)
+			if err != nil {
+				return fmt.Errorf("pointer %q does not match path %q", pointer, path)
+			}
+			path[i].Index = index
+		}
+	}
+	return nil
+}
+
+// Options controls how two decoded documents are compared.
+type Options struct {
+	IgnoreCase bool
+	// ListKeys pairs list elements by identity fields instead of position.
+	ListKeys []ListKey
+	// CSVKey matches the rows of CSV documents by the values of these
+	// columns instead of by position.
+	CSVKey []string
+	// Unordered compares every list as a multiset; UnorderedPaths does so
+	// only for the lists matching the given patterns.
+	Unordered      bool
+	UnorderedPaths []string
+	// Ignore skips the paths matching any of its patterns, and Only, when
+	// set, restricts the comparison to the paths matching one of its
+	// patterns, e.g. "metadata.annotations.*" or "items[*].status".
+	Ignore []string
+	Only   []string
+	// Order selects how differences are ordered; the zero value is
+	// SortDocument.
+	Order SortOrder
+	// AbsTolerance and RelTolerance allow numbers to differ by an absolute
+	// amount or by a fraction of the larger magnitude and still be equal.
+	AbsTolerance float64
+	RelTolerance float64
+	// CoerceScalars compares strings with numbers and booleans by the value
+	// they spell, e.g. "80" with 80, for formats that only have strings.
+	CoerceScalars bool
 }
 
 type FileValidator interface {
@@ -27,23 +124,119 @@ type FileValidator interface {
 	ValidationHelp() string
 }
 
-func CompareValues(a, b interface{}, path string, ignoreCase bool) []Diff {
+type comparer struct {
+	opts      Options
+	listKeys  []compiledListKey
+	unordered []pathPattern
+	ignore    []pathPattern
+	only      []pathPattern
+}
+
+// Validate checks that every path pattern in the options can be parsed.
+func (o Options) Validate() error {
+	_, err := newComparer(o)
+	return err
+}
+
+// newComparer compiles the path patterns of opts. Invalid patterns are
+// skipped so they match nothing; the first error is returned alongside a
+// usable comparer.
+func newComparer(opts Options) (*comparer, error) {
+	c := &comparer{opts: opts}
+	var firstErr error
+	compile := func(compileFn func(string) (pathPattern, error), path string) (pathPattern, bool) {
+		pattern, err := compileFn(path)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			return nil, false
+		}
+		return pattern, true
+	}
+
+	for _, key := range opts.ListKeys {
+		if pattern, ok := compile(compileListPattern, key.Path); ok {
+			c.listKeys = append(c.listKeys, compiledListKey{pattern: pattern, fields: key.Fields})
+		}
+	}
+	for _, path := range opts.UnorderedPaths {
+		if pattern, ok := compile(compileListPattern, path); ok {
+			c.unordered = append(c.unordered, pattern)
+		}
+	}
+	for _, path := range opts.Ignore {
+		if pattern, ok := compile(compilePattern, path); ok {
+			c.ignore = append(c.ignore, pattern)
+		}
+	}
+	for _, path := range opts.Only {
+		if pattern, ok := compile(compilePattern, path); ok {
+			c.only = append(c.only, pattern)
+		}
+	}
+	return c, firstErr
+}
+
+func CompareValues(a, b interface{}, path Path, opts Options) []Diff {
+	c, _ := newComparer(opts)
+	return c.sorted(c.values(a, b, path, nil, nil))
+}
+
+func CompareMaps(a, b map[string]interface{}, path Path, opts Options) []Diff {
+	c, _ := newComparer(opts)
+	return c.sorted(c.maps(a, b, path, nil, nil))
+}
+
+func CompareSlices(a, b []interface{}, path Path, opts Options) []Diff {
+	c, _ := newComparer(opts)
+	return c.sorted(c.slices(a, b, path, nil, nil))
+}
+
+func (c *comparer) sorted(diffs []Diff) []Diff {
+	if c.opts.Order == SortPath {
+		sortDiffs(diffs)
+	}
+	return diffs
+}
+
+// values compares a and b at path. orderA and orderB are the key order nodes
+// of a and b, or nil when unknown.
+func (c *comparer) values(a, b interface{}, path Path, orderA, orderB *keyOrder) []Diff {
 	var diffs []Diff
+	if c.filter(path) == filterSkip {
+		return nil
+	}
 
+	// A null is a value like any other: replacing it, or replacing a value
+	// with it, modifies the key or element, which still exists on both
+	// sides. Reporting it as added or removed would turn it into an add or
+	// remove operation in patches and merges.
 	if a == nil || b == nil {
 		if a != b {
-			diffType := DiffAdded
-			oldVal, newVal := a, b
-			if a == nil {
-				diffType = DiffRemoved
-				oldVal, newVal = b, a
-			}
-			diffs = append(diffs, Diff{
-				Type:     diffType,
+			diffs = append(diffs, c.emit(Diff{
+				Type:     DiffModified,
+				Path:     path,
+				OldValue: a,
+				NewValue: b,
+				order:    orderB,
+			})...)
+		}
+		return diffs
+	}
+
+	if c.opts.CoerceScalars && c.coercedEqual(a, b) {
+		return nil
+	}
+
+	if isNumber(a) && isNumber(b) {
+		if !numbersEqual(a, b, c.opts.AbsTolerance, c.opts.RelTolerance) {
+			diffs = append(diffs, c.emit(Diff{
+				Type:     DiffModified,
 				Path:     path,
-				OldValue: oldVal,
-				NewValue: newVal,
-			})
+				OldValue: a,
+				NewValue: b,
+			})...)
 		}
 		return diffs
 	}
@@ -51,109 +244,194 @@ func CompareValues(a, b interface{}, path string, ignoreCase bool) []Diff {
 	aType := reflect.TypeOf(a)
 	bType := reflect.TypeOf(b)
 	if aType != bType {
-		diffs = append(diffs, Diff{
+		diffs = append(diffs, c.emit(Diff{
 			Type:     DiffModified,
 			Path:     path,
 			OldValue: a,
 			NewValue: b,
-		})
+			order:    orderB,
+		})...)
 		return diffs
 	}
 
 	switch aVal := a.(type) {
 	case map[string]interface{}:
 		bVal := b.(map[string]interface{})
-		diffs = append(diffs, CompareMaps(aVal, bVal, path, ignoreCase)...)
+		diffs = append(diffs, c.maps(aVal, bVal, path, orderA, orderB)...)
 	case []interface{}:
 		bVal := b.([]interface{})
-		diffs = append(diffs, CompareSlices(aVal, bVal, path, ignoreCase)...)
+		diffs = append(diffs, c.slices(aVal, bVal, path, orderA, orderB)...)
 	default:
-		if !DeepEqual(a, b, ignoreCase) {
-			diffs = append(diffs, Diff{
+		if !DeepEqual(a, b, c.opts.IgnoreCase) {
+			diffs = append(diffs, c.emit(Diff{
 				Type:     DiffModified,
 				Path:     path,
 				OldValue: a,
 				NewValue: b,
-			})
+			})...)
 		}
 	}
 	return diffs
 }
 
-func CompareMaps(a, b map[string]interface{}, path string, ignoreCase bool) []Diff {
+func (c *comparer) maps(a, b map[string]interface{}, path Path, orderA, orderB *keyOrder) []Diff {
 	var diffs []Diff
-	allKeys := make(map[string]struct{})
-
-	for key := range a {
-		allKeys[key] = struct{}{}
-	}
-	for key := range b {
-		allKeys[key] = struct{}{}
-	}
-
-	for key := range allKeys {
-		fullPath := path
-		if fullPath != "" {
-			fullPath += "."
-		}
-		fullPath += key
+	for _, key := range c.mapKeys(a, b, orderA, orderB) {
+		fullPath := path.Child(key)
 
 		aVal, aExists := a[key]
 		bVal, bExists := b[key]
 
 		switch {
 		case aExists && bExists:
-			diffs = append(diffs, CompareValues(aVal, bVal, fullPath, ignoreCase)...)
+			diffs = append(diffs, c.values(aVal, bVal, fullPath, orderA.field(key), orderB.field(key))...)
 		case aExists:
-			diffs = append(diffs, Diff{
-				Type:     DiffRemoved,
-				Path:     fullPath,
-				OldValue: aVal,
-			})
+			diffs = append(diffs, c.removed(fullPath, aVal, orderA.field(key))...)
 		case bExists:
-			diffs = append(diffs, Diff{
-				Type:     DiffAdded,
-				Path:     fullPath,
-				NewValue: bVal,
-			})
+			diffs = append(diffs, c.added(fullPath, bVal, orderB.field(key))...)
 		}
 	}
 	return diffs
 }
 
-func CompareSlices(a, b []interface{}, path string, ignoreCase bool) []Diff {
+func (c *comparer) slices(a, b []interface{}, path Path, orderA, orderB *keyOrder) []Diff {
+	if fields := c.listKeyFields(path); fields != nil {
+		if diffs, ok := c.keyedSlices(a, b, path, fields, orderA, orderB); ok {
+			return diffs
+		}
+	}
+	if c.isUnordered(path) {
+		return c.unorderedSlices(a, b, path, orderA, orderB)
+	}
+	return c.sequenceSlices(a, b, path, orderA, orderB)
+}
+
+// sequenceSlices aligns both slices with a shortest edit script, so an
+// insertion no longer shifts every following element. Removed and modified
+// elements are reported at their index in a, added and moved elements at their
+// index in b.
+func (c *comparer) sequenceSlices(a, b []interface{}, path Path, orderA, orderB *keyOrder) []Diff {
 	var diffs []Diff
-	maxLen := len(a)
-	if len(b) > maxLen {
-		maxLen = len(b)
+	edits := myersDiff(len(a), len(b), func(i, j int) bool {
+		return c.equal(a[i], b[j])
+	})
+
+	// Pair deletions with identical insertions elsewhere as moves.
+	movedFrom := make(map[int]int)
+	movedTo := make(map[int]bool)
+	for _, del := range edits {
+		if del.Kind != editDelete {
+			continue
+		}
+		for _, ins := range edits {
+			if ins.Kind != editInsert || movedTo[ins.BIndex] {
+				continue
+			}
+			if c.equal(a[del.AIndex], b[ins.BIndex]) {
+				movedFrom[ins.BIndex] = del.AIndex
+				movedTo[ins.BIndex] = true
+				break
+			}
+		}
+	}
+	moved := make(map[int]bool)
+	for _, i := range movedFrom {
+		moved[i] = true
 	}
 
-	for i := 0; i < maxLen; i++ {
-		fullPath := fmt.Sprintf("%s[%d]", path, i)
-		switch {
-		case i >= len(a):
-			diffs = append(diffs, Diff{
-				Type:     DiffAdded,
-				Path:     fullPath,
-				NewValue: b[i],
-			})
-		case i >= len(b):
-			diffs = append(diffs, Diff{
-				Type:     DiffRemoved,
-				Path:     fullPath,
-				OldValue: a[i],
-			})
-		default:
-			diffs = append(diffs, CompareValues(a[i], b[i], fullPath, ignoreCase)...)
+	// Within each run of changes, pair the remaining deletions with the
+	// remaining insertions and compare them as modifications in place.
+	for start := 0; start < len(edits); {
+		if edits[start].Kind == editEqual {
+			start++
+			continue
+		}
+		end := start
+		var dels, ins []int
+		for ; end < len(edits) && edits[end].Kind != editEqual; end++ {
+			e := edits[end]
+			switch {
+			case e.Kind == editDelete && !moved[e.AIndex]:
+				dels = append(dels, e.AIndex)
+			case e.Kind == editInsert && movedTo[e.BIndex]:
+				diffs = append(diffs, c.emit(Diff{
+					Type:     DiffMoved,
+					Path:     path.Elem(e.BIndex),
+					From:     path.Elem(movedFrom[e.BIndex]),
+					OldValue: a[movedFrom[e.BIndex]],
+					NewValue: b[e.BIndex],
+					order:    orderB.elem(e.BIndex),
+				})...)
+			case e.Kind == editInsert:
+				ins = append(ins, e.BIndex)
+			}
 		}
+
+		for k := 0; k < len(dels) || k < len(ins); k++ {
+			switch {
+			case k >= len(dels):
+				diffs = append(diffs, c.added(path.Elem(ins[k]), b[ins[k]], orderB.elem(ins[k]))...)
+			case k >= len(ins):
+				diffs = append(diffs, c.removed(path.Elem(dels[k]), a[dels[k]], orderA.elem(dels[k]))...)
+			default:
+				fullPath := path.Elem(dels[k])
+				diffs = append(diffs, c.values(a[dels[k]], b[ins[k]], fullPath, orderA.elem(dels[k]), orderB.elem(ins[k]))...)
+			}
+		}
+		start = end
 	}
 	return diffs
 }
 
+// equal reports whether two decoded trees are structurally equal. Lists are
+// compared as multisets when the comparison is globally unordered.
+func (c *comparer) equal(a, b interface{}) bool {
+	switch aVal := a.(type) {
+	case map[string]interface{}:
+		bVal, ok := b.(map[string]interface{})
+		if !ok || len(aVal) != len(bVal) {
+			return false
+		}
+		for key, av := range aVal {
+			bv, exists := bVal[key]
+			if !exists || !c.equal(av, bv) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		bVal, ok := b.([]interface{})
+		if !ok || len(aVal) != len(bVal) {
+			return false
+		}
+		if c.opts.Unordered {
+			removed, added := c.multisetDifference(aVal, bVal)
+			return len(removed) == 0 && len(added) == 0
+		}
+		for i := range aVal {
+			if !c.equal(aVal[i], bVal[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		if c.opts.CoerceScalars && c.coercedEqual(a, b) {
+			return true
+		}
+		if isNumber(a) && isNumber(b) {
+			return numbersEqual(a, b, c.opts.AbsTolerance, c.opts.RelTolerance)
+		}
+		return DeepEqual(a, b, c.opts.IgnoreCase)
+	}
+}
+
 func DeepEqual(a, b interface{}, ignoreCase bool) bool {
 	if a == nil || b == nil {
 		return a == nil && b == nil
 	}
+	if isNumber(a) && isNumber(b) {
+		return numbersEqual(a, b, 0, 0)
+	}
 
 	switch aVal := a.(type) {
 	case string:
@@ -168,9 +446,6 @@ func DeepEqual(a, b interface{}, ignoreCase bool) bool {
 	case bool:
 		bVal, ok := b.(bool)
 		return ok && aVal == bVal
-	case float64:
-		bVal, ok := b.(float64)
-		return ok && aVal == bVal
 	default:
 		return a == b
 	}
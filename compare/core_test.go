@@ -0,0 +1,57 @@
+package compare
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompareValuesNull(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []Diff
+	}{
+		{
+			name: "null replaced",
+			a:    `{"a": null}`, b: `{"a": 1}`,
+			want: []Diff{{Type: DiffModified, Path: Path{}.Child("a"), OldValue: nil, NewValue: 1.0}},
+		},
+		{
+			name: "replaced by null",
+			a:    `{"a": "x"}`, b: `{"a": null}`,
+			want: []Diff{{Type: DiffModified, Path: Path{}.Child("a"), OldValue: "x", NewValue: nil}},
+		},
+		{
+			name: "null element replaced",
+			a:    `[null]`, b: `[{"b": 2}]`,
+			want: []Diff{{Type: DiffModified, Path: Path{}.Elem(0), OldValue: nil, NewValue: map[string]interface{}{"b": 2.0}}},
+		},
+		{
+			name: "null key added",
+			a:    `{}`, b: `{"a": null}`,
+			want: []Diff{{Type: DiffAdded, Path: Path{}.Child("a"), NewValue: nil}},
+		},
+		{
+			name: "null key removed",
+			a:    `{"a": null}`, b: `{}`,
+			want: []Diff{{Type: DiffRemoved, Path: Path{}.Child("a"), OldValue: nil}},
+		},
+		{name: "null unchanged", a: `{"a": null}`, b: `{"a": null}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := mustDecodeJSON(t, tt.a), mustDecodeJSON(t, tt.b)
+			got := CompareValues(a, b, nil, Options{})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("CompareValues = %+v, want %+v", got, tt.want)
+			}
+			patched, err := ApplyPatch(copyValue(a), JSONPatch(got))
+			if err != nil {
+				t.Fatalf("ApplyPatch: %v", err)
+			}
+			if !reflect.DeepEqual(patched, b) {
+				t.Errorf("patch produced %v, want %v", patched, b)
+			}
+		})
+	}
+}
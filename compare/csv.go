@@ -4,12 +4,41 @@ import (
 	"bytes"
 	"encoding/csv"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 )
 
-type CSVValidator struct{}
+// CSVOptions describes the dialect of CSV documents.
+type CSVOptions struct {
+	// Delimiter separates fields. The zero value means a comma, or a tab
+	// for files named *.tsv.
+	Delimiter rune
+	// NoHeader treats the first record as data. Cells are then keyed by
+	// their 0-based column index.
+	NoHeader bool
+	// Comment, when set, starts lines that are ignored.
+	Comment rune
+	// LazyQuotes accepts quotes in unquoted fields and unescaped quotes in
+	// quoted fields.
+	LazyQuotes bool
+	// Ragged accepts records with varying numbers of fields. Missing cells
+	// are absent from their row, and cells beyond the header are keyed by
+	// their column index.
+	Ragged bool
+	// InferTypes converts the cells of a column to integers, floats,
+	// booleans or dates when all its non-empty cells spell one, so that
+	// e.g. 1 and 1.0 or TRUE and true are equal. Dates are written in ISO
+	// 8601 form.
+	InferTypes bool
+}
+
+type CSVValidator struct {
+	Options CSVOptions
+}
 
 func (c *CSVValidator) Validate(content []byte) error {
-	_, err := csv.NewReader(bytes.NewReader(content)).ReadAll()
+	_, err := readCSV(content, c.Options)
 	return err
 }
 
@@ -25,54 +54,229 @@ type CSVComparator struct {
 	CSVValidator
 }
 
-func (c *CSVComparator) Compare(file1, file2 string, ignoreCase bool, config RemoteConfig) ([]Diff, error) {
-	data1, err := readFileContent(file1, config)
-	if err != nil {
-		return nil, fmt.Errorf("error reading first file: %w", err)
+// Compare compares two CSV documents. When opts.CSVKey is set, rows are
+// matched by the values of its columns and their order is ignored. Cells are
+// compared as in CoerceScalars, since a column may have a type inferred in
+// one document only.
+func (c *CSVComparator) Compare(doc1, doc2 *Document, opts Options) ([]Diff, error) {
+	opts.CoerceScalars = true
+	if len(opts.CSVKey) > 0 {
+		for _, doc := range []*Document{doc1, doc2} {
+			if err := checkCSVKey(doc, opts.CSVKey); err != nil {
+				return nil, err
+			}
+		}
+		opts.ListKeys = append([]ListKey{{Path: csvRows, Fields: opts.CSVKey}}, opts.ListKeys...)
+		opts.UnorderedPaths = append([]string{csvRows}, opts.UnorderedPaths...)
 	}
+	return compareTrees(doc1, doc2, opts), nil
+}
 
-	data2, err := readFileContent(file2, config)
-	if err != nil {
-		return nil, fmt.Errorf("error reading second file: %w", err)
+// checkCSVKey verifies that the key columns identify every row of doc.
+func checkCSVKey(doc *Document, columns []string) error {
+	rows, _ := member(doc.Tree, csvRows).([]interface{})
+	seen := make(map[string]int, len(rows))
+	for i, row := range rows {
+		values := make([]interface{}, len(columns))
+		for j, column := range columns {
+			value, exists := row.(map[string]interface{})[column]
+			if !exists {
+				return fmt.Errorf("%s: key column %q not found", doc.Source, column)
+			}
+			values[j] = value
+		}
+		key := formatSelector(columns, values)
+		if first, exists := seen[key]; exists {
+			return fmt.Errorf("%s: rows %d and %d share the key %s", doc.Source, first+1, i+1, key)
+		}
+		seen[key] = i
 	}
+	return nil
+}
+
+func (c *CSVComparator) Validator() FileValidator {
+	return &c.CSVValidator
+}
 
-	records1, err := csv.NewReader(bytes.NewReader(data1)).ReadAll()
+func (c *CSVComparator) parse(data []byte, name string) (interface{}, *keyOrder, error) {
+	records, err := readCSV(data, c.Options)
 	if err != nil {
-		return nil, fmt.Errorf("error parsing first CSV file: %w", err)
+		return nil, nil, err
 	}
+	tree, order := csvToTree(records, c.Options)
+	return tree, order, nil
+}
 
-	records2, err := csv.NewReader(bytes.NewReader(data2)).ReadAll()
-	if err != nil {
-		return nil, fmt.Errorf("error parsing second CSV file: %w", err)
+// readCSV reads all records of a document in the dialect of opts, skipping
+// a leading UTF-8 byte order mark.
+func readCSV(data []byte, opts CSVOptions) ([][]string, error) {
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	if opts.Delimiter != 0 {
+		r.Comma = opts.Delimiter
 	}
+	r.Comment = opts.Comment
+	r.LazyQuotes = opts.LazyQuotes
+	if opts.Ragged {
+		r.FieldsPerRecord = -1
+	}
+	return r.ReadAll()
+}
 
-	obj1 := csvToMaps(records1)
-	obj2 := csvToMaps(records2)
+// csvRows is the key holding the rows of a CSV document.
+const csvRows = "row"
 
-	return CompareValues(obj1, obj2, "", ignoreCase), nil
-}
+// csvToTree converts CSV records into a map holding the list of rows under
+// "row", each row a map from the column names of the header, or the column
+// indexes without one, to its cells. Cells thus have paths such as
+// row[3].email, or row[id=42].email when rows are matched by key.
+func csvToTree(records [][]string, opts CSVOptions) (map[string]interface{}, *keyOrder) {
+	var headers []string
+	if !opts.NoHeader && len(records) > 0 {
+		headers, records = records[0], records[1:]
+	}
 
-func (c *CSVComparator) Validator() FileValidator {
-	return &c.CSVValidator
+	rows := []interface{}{}
+	rowsOrder := &keyOrder{}
+	for _, record := range records {
+		row := make(map[string]interface{}, len(record))
+		keys := make([]string, len(record))
+		for i, value := range record {
+			keys[i] = strconv.Itoa(i)
+			if i < len(headers) {
+				keys[i] = headers[i]
+			}
+			row[keys[i]] = value
+		}
+		rows = append(rows, row)
+		rowsOrder.elems = append(rowsOrder.elems, mapOrder(keys))
+	}
+	if opts.InferTypes {
+		inferCSVTypes(rows)
+	}
+
+	order := mapOrder([]string{csvRows})
+	order.setField(csvRows, rowsOrder)
+	return map[string]interface{}{csvRows: rows}, order
 }
 
-func csvToMaps(records [][]string) []map[string]interface{} {
-	if len(records) == 0 {
-		return nil
+// csvDateLayouts are the date formats recognized by type inference. Dates
+// without a time are written without one.
+var csvDateLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+// inferCSVTypes converts the cells of each column of rows to the most
+// specific type spelled by all of its non-empty cells.
+func inferCSVTypes(rows []interface{}) {
+	columns := make(map[string][]string)
+	for _, row := range rows {
+		for column, cell := range row.(map[string]interface{}) {
+			if cell != "" {
+				columns[column] = append(columns[column], cell.(string))
+			}
+		}
 	}
 
-	headers := records[0]
-	var result []map[string]interface{}
+	for column, cells := range columns {
+		convert := csvConverter(cells)
+		if convert == nil {
+			continue
+		}
+		for _, row := range rows {
+			m := row.(map[string]interface{})
+			if cell, ok := m[column].(string); ok && cell != "" {
+				m[column] = convert(cell)
+			}
+		}
+	}
+}
 
-	for _, record := range records[1:] {
-		row := make(map[string]interface{})
-		for i, value := range record {
-			if i < len(headers) {
-				row[headers[i]] = value
+// csvConverter returns the conversion that applies to all cells, or nil.
+func csvConverter(cells []string) func(string) interface{} {
+	all := func(valid func(string) bool) bool {
+		for _, cell := range cells {
+			if !valid(cell) {
+				return false
 			}
 		}
-		result = append(result, row)
+		return true
 	}
 
-	return result
+	switch {
+	case all(func(s string) bool { _, err := strconv.ParseInt(s, 10, 64); return err == nil }):
+		return func(s string) interface{} {
+			n, _ := strconv.ParseInt(s, 10, 64)
+			return n
+		}
+	case all(isDecimal):
+		return func(s string) interface{} {
+			f, _ := strconv.ParseFloat(s, 64)
+			return f
+		}
+	case all(func(s string) bool { return strings.EqualFold(s, "true") || strings.EqualFold(s, "false") }):
+		return func(s string) interface{} {
+			return strings.EqualFold(s, "true")
+		}
+	case all(func(s string) bool { _, ok := parseCSVDate(s); return ok }):
+		return func(s string) interface{} {
+			date, _ := parseCSVDate(s)
+			return date
+		}
+	}
+	return nil
+}
+
+// isDecimal reports whether s is a finite number, which excludes the
+// spellings of infinities and NaN that strconv.ParseFloat accepts.
+func isDecimal(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil && strings.ContainsAny(s, "0123456789")
+}
+
+func parseCSVDate(s string) (string, bool) {
+	for _, layout := range csvDateLayouts {
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			continue
+		}
+		if layout == "2006-01-02" {
+			return t.Format(layout), true
+		}
+		return t.Format(time.RFC3339Nano), true
+	}
+	return "", false
+}
+
+// ColumnChange counts the rows in which cells of a CSV column changed.
+type ColumnChange struct {
+	Column string `json:"column"`
+	Rows   int    `json:"rows"`
+}
+
+// CSVColumnChanges summarizes the differences between two CSV documents by
+// column, in order of first change. Cells added to or removed from a row
+// count as changes, but rows added, removed or moved as a whole do not.
+func CSVColumnChanges(diffs []Diff) []ColumnChange {
+	var changes []ColumnChange
+	index := make(map[string]int)
+	seen := make(map[[2]string]bool)
+	for _, d := range diffs {
+		if d.Type == DiffMoved || len(d.Path) < 3 || d.Path[0].Key != csvRows ||
+			!d.Path[1].Element || d.Path[2].Element {
+			continue
+		}
+		column := d.Path[2].Key
+		row := Path{d.Path[1]}.String()
+		if seen[[2]string{column, row}] {
+			continue
+		}
+		seen[[2]string{column, row}] = true
+
+		i, exists := index[column]
+		if !exists {
+			i = len(changes)
+			index[column] = i
+			changes = append(changes, ColumnChange{Column: column})
+		}
+		changes[i].Rows++
+	}
+	return changes
 }
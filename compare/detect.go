@@ -0,0 +1,299 @@
+package compare
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Detection is the outcome of format detection.
+type Detection struct {
+	Format string
+	// Confidence ranges from 0 to 1: 0.9 and above for file extensions,
+	// registered media types and content that parses as the format, lower
+	// for content that merely looks like it.
+	Confidence float64
+	// Method is what the detection is based on: "extension",
+	// "content-type" or "content".
+	Method string
+}
+
+func (d Detection) String() string {
+	return fmt.Sprintf("%s (%s, confidence %.0f%%)", d.Format, d.Method, d.Confidence*100)
+}
+
+// templateSuffixes are stripped from file names before looking at their
+// extension, so that e.g. values.yaml.tmpl is detected as YAML.
+var templateSuffixes = []string{
+	".tmpl", ".tpl", ".template", ".gotmpl", ".j2", ".jinja", ".jinja2",
+	".erb", ".in", ".dist", ".example", ".sample",
+}
+
+// DetectFormat detects the format of a file from its name, recognizing
+// compound extensions such as .hcl.json and template suffixes such as .tmpl.
+func DetectFormat(filename string) (string, error) {
+	name := untemplatedName(filename)
+	if strings.HasSuffix(name, ".hcl.json") || strings.HasSuffix(name, ".json.hcl") {
+		return "hcljson", nil
+	}
+	ext := filepath.Ext(name)
+	switch ext {
+	case ".json":
+		return "json", nil
+	case ".yaml", ".yml":
+		return "yaml", nil
+	case ".toml":
+		return "toml", nil
+	case ".xml":
+		return "xml", nil
+	case ".ini", ".cfg":
+		return "ini", nil
+	case ".csv", ".tsv":
+		return "csv", nil
+	case ".hcl":
+		return "hcl", nil
+	default:
+		return "", fmt.Errorf("unsupported file format: %s", ext)
+	}
+}
+
+// untemplatedName returns the lower-cased base name of a file without its
+// template suffixes.
+func untemplatedName(filename string) string {
+	name := strings.ToLower(filepath.Base(filename))
+	for trimmed := true; trimmed; {
+		trimmed = false
+		for _, suffix := range templateSuffixes {
+			if strings.HasSuffix(name, suffix) && len(name) > len(suffix) {
+				name = strings.TrimSuffix(name, suffix)
+				trimmed = true
+			}
+		}
+	}
+	return name
+}
+
+// DetectDocumentFormats detects the format shared by two fetched documents.
+// Each document is detected with DetectDocumentFormat and the more confident
+// detection wins, so that e.g. a file named config.yaml decides the format of
+// stdin.
+func DetectDocumentFormats(doc1, doc2 *Document) (Detection, error) {
+	d1, err1 := DetectDocumentFormat(doc1)
+	d2, err2 := DetectDocumentFormat(doc2)
+	return moreConfident(d1, err1, d2, err2)
+}
+
+func moreConfident(d1 Detection, err1 error, d2 Detection, err2 error) (Detection, error) {
+	switch {
+	case err1 != nil && err2 != nil:
+		return Detection{}, err1
+	case err1 != nil:
+		return d2, nil
+	case err2 != nil:
+		return d1, nil
+	case d2.Confidence > d1.Confidence:
+		return d2, nil
+	}
+	return d1, nil
+}
+
+// DetectFormatPair detects the format of each of two documents for a
+// comparison across formats. A document detected from its content with less
+// confidence than the other takes the other's format when its content is
+// valid in it, and a document that cannot be detected at all takes the
+// other's format.
+func DetectFormatPair(doc1, doc2 *Document) (Detection, Detection, error) {
+	d1, err1 := DetectDocumentFormat(doc1)
+	d2, err2 := DetectDocumentFormat(doc2)
+	switch {
+	case err1 != nil && err2 != nil:
+		return d1, d2, err1
+	case err1 != nil:
+		return d2, d2, nil
+	case err2 != nil:
+		return d1, d1, nil
+	case d1.Format == d2.Format:
+		return d1, d2, nil
+	case d1.Method == "content" && d1.Confidence < d2.Confidence && validIn(doc1, d2.Format):
+		return d2, d2, nil
+	case d2.Method == "content" && d2.Confidence < d1.Confidence && validIn(doc2, d1.Format):
+		return d1, d1, nil
+	}
+	return d1, d2, nil
+}
+
+func validIn(doc *Document, format string) bool {
+	probe := &Document{Source: doc.Source, Content: doc.Content}
+	return probe.Parse(format, RemoteConfig{}, ParseOptions{}) == nil
+}
+
+// DetectDocumentFormat detects the format of a fetched document from its
+// source name, then from the Content-Type of remote files, then from its
+// content.
+func DetectDocumentFormat(doc *Document) (Detection, error) {
+	if d, ok := detectName(doc.Source); ok {
+		return d, nil
+	}
+	if format := mediaTypeFormat(doc.ContentType); format != "" {
+		return Detection{Format: format, Confidence: 0.9, Method: "content-type"}, nil
+	}
+	if d := SniffFormat(doc.Content); d.Format != "" {
+		return d, nil
+	}
+	return Detection{}, fmt.Errorf("cannot detect the format of %s", doc.Source)
+}
+
+func detectName(source string) (Detection, bool) {
+	if source == "-" {
+		return Detection{}, false
+	}
+	format, err := DetectFormat(sourceName(source))
+	if err != nil {
+		return Detection{}, false
+	}
+	return Detection{Format: format, Confidence: 0.95, Method: "extension"}, true
+}
+
+// sourceName returns the file name part of a source, without the query and
+// fragment of URLs.
+func sourceName(source string) string {
+	if strings.HasPrefix(source, "https://") {
+		return strings.SplitN(strings.SplitN(source, "?", 2)[0], "#", 2)[0]
+	}
+	return source
+}
+
+func mediaTypeFormat(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return "json"
+	case mediaType == "application/yaml" || mediaType == "application/x-yaml" ||
+		mediaType == "text/yaml" || mediaType == "text/x-yaml" || strings.HasSuffix(mediaType, "+yaml"):
+		return "yaml"
+	case mediaType == "application/toml" || mediaType == "text/x-toml":
+		return "toml"
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return "xml"
+	case mediaType == "text/csv":
+		return "csv"
+	}
+	return ""
+}
+
+var (
+	hclBlockLine = regexp.MustCompile(`(?m)^\s*[A-Za-z_][\w-]*(\s+("[^"]*"|[A-Za-z_][\w-]*))*\s*\{\s*$`)
+	tableLine    = regexp.MustCompile(`(?m)^\s*\[\[?[^\]]+\]\]?\s*$`)
+	assignLine   = regexp.MustCompile(`(?m)^\s*[A-Za-z0-9_.\-"]+\s*=`)
+)
+
+// SniffFormat guesses the format of a document from its content. Formats
+// that are hard to tell apart, such as TOML and HCL attributes, get a lower
+// confidence. The zero Detection is returned when nothing matches.
+func SniffFormat(data []byte) Detection {
+	content := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	if len(content) == 0 {
+		return Detection{}
+	}
+	sniffed := func(format string, confidence float64) Detection {
+		return Detection{Format: format, Confidence: confidence, Method: "content"}
+	}
+
+	switch content[0] {
+	case '{':
+		if json.Valid(content) {
+			return sniffed("json", 0.95)
+		}
+	case '[':
+		if json.Valid(content) {
+			return sniffed("json", 0.95)
+		}
+	case '<':
+		if bytes.HasPrefix(content, []byte("<?xml")) {
+			return sniffed("xml", 0.95)
+		}
+		if parses(&XMLValidator{}, data) {
+			return sniffed("xml", 0.8)
+		}
+	}
+
+	if bytes.HasPrefix(content, []byte("---")) || bytes.HasPrefix(content, []byte("%YAML")) {
+		return sniffed("yaml", 0.9)
+	}
+
+	tables := tableLine.Match(content)
+	assigns := assignLine.Match(content)
+	if tables || assigns {
+		switch {
+		case parses(&TOMLValidator{}, data) && tables:
+			return sniffed("toml", 0.9)
+		case hclBlockLine.Match(content) && parses(&HCLValidator{}, data):
+			return sniffed("hcl", 0.85)
+		case parses(&TOMLValidator{}, data):
+			// Plain "key = value" lines are valid TOML and HCL alike.
+			return sniffed("toml", 0.6)
+		case tables && parses(&INIValidator{}, data):
+			return sniffed("ini", 0.7)
+		}
+	}
+
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err == nil {
+		switch doc.(type) {
+		case map[string]interface{}, []interface{}:
+			return sniffed("yaml", 0.7)
+		}
+	}
+
+	if looksLikeCSV(content) {
+		return sniffed("csv", 0.6)
+	}
+	if assigns && parses(&INIValidator{}, data) {
+		return sniffed("ini", 0.5)
+	}
+	return Detection{}
+}
+
+// looksLikeCSV reports whether content has at least two lines with the same
+// number of fields, more than one.
+func looksLikeCSV(content []byte) bool {
+	lines := bytes.Split(content, []byte("\n"))
+	if len(lines) < 2 {
+		return false
+	}
+	fields := bytes.Count(lines[0], []byte(",")) + 1
+	if fields < 2 {
+		return false
+	}
+	return parses(&CSVValidator{}, content)
+}
+
+func parses(v FileValidator, data []byte) bool {
+	return v.Validate(data) == nil
+}
+
+// stdin is read at most once, so that it can be both sniffed and compared.
+var stdin struct {
+	once sync.Once
+	data []byte
+	err  error
+}
+
+func readStdin() ([]byte, error) {
+	stdin.once.Do(func() {
+		stdin.data, stdin.err = io.ReadAll(os.Stdin)
+	})
+	return stdin.data, stdin.err
+}
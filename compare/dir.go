@@ -0,0 +1,154 @@
+package compare
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// FileStatus describes how a file differs between two directories.
+type FileStatus string
+
+const (
+	FileAdded     FileStatus = "added"
+	FileRemoved   FileStatus = "removed"
+	FileModified  FileStatus = "modified"
+	FileUnchanged FileStatus = "unchanged"
+	FileError     FileStatus = "error"
+)
+
+// FileDiff is the result of comparing one file of a directory comparison.
+// Files in formats DetectFormat does not recognize are compared byte by
+// byte and reported as modified without Diffs when they differ.
+type FileDiff struct {
+	Path   string     `json:"path"`
+	Status FileStatus `json:"status"`
+	Format string     `json:"format,omitempty"`
+	Diffs  []Diff     `json:"diffs,omitempty"`
+	Error  string     `json:"error,omitempty"`
+}
+
+// CompareDirs walks two directory trees, pairs their files by relative path
+// and compares each pair in the format detected from its name, or in format
+// unless it is "auto" or empty. Paths use forward slashes, and .git
+// directories are skipped.
+func CompareDirs(dir1, dir2, format string, opts Options, config RemoteConfig, parseOpts ParseOptions) ([]FileDiff, error) {
+	files1, err := listFiles(dir1)
+	if err != nil {
+		return nil, err
+	}
+	files2, err := listFiles(dir2)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for path := range files1 {
+		paths = append(paths, path)
+	}
+	for path := range files2 {
+		if !files1[path] {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	results := make([]FileDiff, 0, len(paths))
+	for _, path := range paths {
+		result := FileDiff{Path: path}
+		switch {
+		case !files2[path]:
+			result.Status = FileRemoved
+		case !files1[path]:
+			result.Status = FileAdded
+		default:
+			file1 := filepath.Join(dir1, filepath.FromSlash(path))
+			file2 := filepath.Join(dir2, filepath.FromSlash(path))
+			compareDirFile(&result, file1, file2, format, opts, config, parseOpts)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func compareDirFile(result *FileDiff, file1, file2, format string, opts Options, config RemoteConfig, parseOpts ParseOptions) {
+	fail := func(err error) {
+		result.Status, result.Error = FileError, err.Error()
+	}
+	doc1, err := FetchDocument(file1, config)
+	if err != nil {
+		fail(err)
+		return
+	}
+	doc2, err := FetchDocument(file2, config)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	fileFormat := format
+	if fileFormat == "" || fileFormat == "auto" {
+		detected, err := DetectFormat(file1)
+		if err != nil {
+			if bytes.Equal(doc1.Content, doc2.Content) {
+				result.Status = FileUnchanged
+			} else {
+				result.Status = FileModified
+			}
+			return
+		}
+		fileFormat = detected
+	}
+	result.Format = fileFormat
+
+	for _, doc := range []*Document{doc1, doc2} {
+		if err := doc.Parse(fileFormat, config, parseOpts); err != nil {
+			fail(err)
+			return
+		}
+	}
+	diffs, err := CompareDocuments(doc1, doc2, opts)
+	switch {
+	case err != nil:
+		fail(err)
+	case len(diffs) > 0:
+		result.Status, result.Diffs = FileModified, diffs
+	default:
+		result.Status = FileUnchanged
+	}
+}
+
+// listFiles returns the set of regular files below root, by slash-separated
+// relative path.
+func listFiles(root string) (map[string]bool, error) {
+	files := make(map[string]bool)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" && path != root {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = true
+		return nil
+	})
+	return files, err
+}
+
+// IsDir reports whether source names a local directory.
+func IsDir(source string) bool {
+	info, err := os.Stat(source)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,189 @@
+package compare
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Document is a source read once and parsed into the tree comparators work
+// on. Its tree must not be modified, as documents may be shared between
+// comparisons.
+type Document struct {
+	Source string
+	Format string
+	// Content is the source as read, and ContentType the media type
+	// reported by the server for remote files.
+	Content     []byte
+	ContentType string
+	Tree        interface{}
+
+	order *keyOrder
+}
+
+// FetchDocument reads a source without parsing it, e.g. so that its format
+// can be detected from its content before calling Parse.
+func FetchDocument(source string, config RemoteConfig) (*Document, error) {
+	content, contentType, err := fetchSource(source, config)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", source, err)
+	}
+	return &Document{Source: source, Content: content, ContentType: contentType}, nil
+}
+
+// LoadDocument reads, validates and parses a source in format.
+func LoadDocument(source, format string, config RemoteConfig, parseOpts ParseOptions) (*Document, error) {
+	doc, err := FetchDocument(source, config)
+	if err != nil {
+		return nil, err
+	}
+	if err := doc.Parse(format, config, parseOpts); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// Parse parses the content of the document in format with parseOpts,
+// validating it first unless config.SkipValidate is set.
+func (d *Document) Parse(format string, config RemoteConfig, parseOpts ParseOptions) error {
+	comparator, err := newParser(format, d.Source, parseOpts)
+	if err != nil {
+		return err
+	}
+	if validator := comparator.Validator(); !config.SkipValidate && validator != nil {
+		if err := validator.Validate(d.Content); err != nil {
+			return fmt.Errorf("validation of %s failed: %w\n%s", d.Source, err, validator.ValidationHelp())
+		}
+	}
+
+	tree, order, err := comparator.(documentParser).parse(d.Content, d.Source)
+	if err != nil {
+		return fmt.Errorf("error parsing %s: %w", d.Source, err)
+	}
+	d.Format, d.Tree, d.order = format, tree, order
+	return nil
+}
+
+// CompareDocuments compares two parsed documents. Documents in different
+// formats are compared as described for CompareFilesAs.
+func CompareDocuments(doc1, doc2 *Document, opts Options) ([]Diff, error) {
+	if !strings.EqualFold(doc1.Format, doc2.Format) {
+		opts.CoerceScalars = true
+		doc1, doc2 = doc1.normalized(), doc2.normalized()
+	}
+	comparator, err := newComparator(doc1.Format)
+	if err != nil {
+		return nil, err
+	}
+	return comparator.Compare(doc1, doc2, opts)
+}
+
+// patchBaser is implemented by comparators that compare a reshaped copy of
+// the first document, returning that copy and the operations producing it.
+type patchBaser interface {
+	patchBase(doc1, doc2 *Document) (interface{}, []PatchOperation)
+}
+
+// PatchBase returns the tree the differences between doc1 and doc2 are
+// relative to, along with the JSON Patch operations turning the tree of doc1
+// into it. This is the tree of doc1 itself, except for XML documents with
+// elements repeated in doc2 only, which are compared as lists. Patches built
+// from the differences apply to the returned tree, or to doc1 once the
+// operations are applied first.
+func PatchBase(doc1, doc2 *Document) (interface{}, []PatchOperation) {
+	if strings.EqualFold(doc1.Format, doc2.Format) {
+		if comparator, err := newComparator(doc1.Format); err == nil {
+			if p, ok := comparator.(patchBaser); ok {
+				return p.patchBase(doc1, doc2)
+			}
+		}
+	}
+	return doc1.Tree, nil
+}
+
+// compareTrees compares the trees of two documents in the source order of
+// their keys.
+func compareTrees(doc1, doc2 *Document, opts Options) []Diff {
+	return compareOrdered(doc1.Tree, doc2.Tree, doc1.order, doc2.order, opts)
+}
+
+// compareOrdered compares two trees in the key order of orderA and orderB.
+func compareOrdered(a, b interface{}, orderA, orderB *keyOrder, opts Options) []Diff {
+	c, _ := newComparer(opts)
+	return c.sorted(c.values(a, b, nil, orderA, orderB))
+}
+
+// normalized returns a copy of the document with its tree normalized for
+// comparison with other formats, leaving the document itself untouched. The
+// normalized tree has the same shape, so it shares the key order.
+func (d *Document) normalized() *Document {
+	normalized := *d
+	normalized.Tree = normalizeTree(d.Tree)
+	return &normalized
+}
+
+// DocumentCache fetches each source once and parses it at most once for each
+// format and set of parse options, so that a source appearing in several
+// comparisons is read and parsed once. It is safe for concurrent use.
+type DocumentCache struct {
+	config  RemoteConfig
+	mu      sync.Mutex
+	entries map[cacheKey]*cachedDocument
+}
+
+type cacheKey struct {
+	source, format string
+	parseOpts      ParseOptions
+}
+
+type cachedDocument struct {
+	once sync.Once
+	doc  *Document
+	err  error
+}
+
+// NewDocumentCache returns an empty cache loading documents with config.
+func NewDocumentCache(config RemoteConfig) *DocumentCache {
+	return &DocumentCache{config: config, entries: make(map[cacheKey]*cachedDocument)}
+}
+
+// Fetch returns the unparsed document for source, fetching it on first use.
+func (c *DocumentCache) Fetch(source string) (*Document, error) {
+	entry := c.entry(cacheKey{source: source})
+	entry.once.Do(func() {
+		entry.doc, entry.err = FetchDocument(source, c.config)
+	})
+	return entry.doc, entry.err
+}
+
+// Load returns the document for source parsed in format with parseOpts,
+// loading it on first use. Errors are cached as well.
+func (c *DocumentCache) Load(source, format string, parseOpts ParseOptions) (*Document, error) {
+	if _, err := newComparator(format); err != nil {
+		return nil, err
+	}
+	entry := c.entry(cacheKey{source: source, format: strings.ToLower(format), parseOpts: parseOpts})
+	entry.once.Do(func() {
+		fetched, err := c.Fetch(source)
+		if err != nil {
+			entry.err = err
+			return
+		}
+		doc := *fetched
+		if entry.err = doc.Parse(format, c.config, parseOpts); entry.err == nil {
+			entry.doc = &doc
+		}
+	})
+	return entry.doc, entry.err
+}
+
+func (c *DocumentCache) entry(key cacheKey) *cachedDocument {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, exists := c.entries[key]
+	if !exists {
+		entry = &cachedDocument{}
+		c.entries[key] = entry
+	}
+	return entry
+}
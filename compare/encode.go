@@ -0,0 +1,155 @@
+package compare
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// encodeDocument serializes a tree in one of the writable formats. Maps are
+// written in the key order recorded when they were parsed, if any.
+func encodeDocument(doc interface{}, format string, order *keyOrder) ([]byte, error) {
+	doc = plainNumbers(doc)
+	switch strings.ToLower(format) {
+	case "json":
+		var buf bytes.Buffer
+		if err := writeJSON(&buf, doc, order, ""); err != nil {
+			return nil, err
+		}
+		buf.WriteByte('\n')
+		return buf.Bytes(), nil
+	case "yaml":
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		node, err := yamlNode(doc, order)
+		if err != nil {
+			return nil, err
+		}
+		if err := enc.Encode(node); err != nil {
+			return nil, err
+		}
+		if err := enc.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case "toml":
+		if _, ok := doc.(map[string]interface{}); !ok {
+			return nil, fmt.Errorf("TOML documents must be tables, got %T", doc)
+		}
+		return toml.Marshal(doc)
+	}
+	return nil, fmt.Errorf("writing %s files is not supported (json, yaml and toml are)", format)
+}
+
+// plainNumbers converts json.Number values into int64 or float64 so that every
+// encoder writes them as numbers of the appropriate kind.
+func plainNumbers(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for key, value := range t {
+			t[key] = plainNumbers(value)
+		}
+		return t
+	case []interface{}:
+		for i, value := range t {
+			t[i] = plainNumbers(value)
+		}
+		return t
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i
+		}
+		if f, err := t.Float64(); err == nil {
+			return f
+		}
+		return string(t)
+	}
+	return v
+}
+
+func writeJSON(buf *bytes.Buffer, v interface{}, order *keyOrder, indent string) error {
+	inner := indent + "  "
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if len(t) == 0 {
+			buf.WriteString("{}")
+			return nil
+		}
+		buf.WriteString("{\n")
+		for i, key := range order.keys(t) {
+			if i > 0 {
+				buf.WriteString(",\n")
+			}
+			buf.WriteString(inner + strconv.Quote(key) + ": ")
+			if err := writeJSON(buf, t[key], order.field(key), inner); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("\n" + indent + "}")
+	case []interface{}:
+		if len(t) == 0 {
+			buf.WriteString("[]")
+			return nil
+		}
+		buf.WriteString("[\n")
+		for i, value := range t {
+			if i > 0 {
+				buf.WriteString(",\n")
+			}
+			buf.WriteString(inner)
+			if err := writeJSON(buf, value, order.elem(i), inner); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("\n" + indent + "]")
+	default:
+		var scalar bytes.Buffer
+		enc := json.NewEncoder(&scalar)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		buf.Write(bytes.TrimRight(scalar.Bytes(), "\n"))
+	}
+	return nil
+}
+
+func yamlNode(v interface{}, order *keyOrder) (*yaml.Node, error) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, key := range order.keys(t) {
+			keyNode := &yaml.Node{}
+			if err := keyNode.Encode(key); err != nil {
+				return nil, err
+			}
+			valueNode, err := yamlNode(t[key], order.field(key))
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, keyNode, valueNode)
+		}
+		return node, nil
+	case []interface{}:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for i, value := range t {
+			valueNode, err := yamlNode(value, order.elem(i))
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, valueNode)
+		}
+		return node, nil
+	}
+	node := &yaml.Node{}
+	if err := node.Encode(v); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
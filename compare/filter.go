@@ -0,0 +1,75 @@
+package compare
+
+type filterMode int
+
+const (
+	filterInclude filterMode = iota
+	// filterDescend marks an ancestor of an --only path: it is not reported
+	// itself, but the comparison continues below it.
+	filterDescend
+	filterSkip
+)
+
+func (c *comparer) filter(path Path) filterMode {
+	for _, pattern := range c.ignore {
+		if pattern.match(path) {
+			return filterSkip
+		}
+	}
+	if len(c.only) == 0 {
+		return filterInclude
+	}
+
+	for _, pattern := range c.only {
+		for i := len(path); i > 0; i-- {
+			if pattern.match(path[:i]) {
+				return filterInclude
+			}
+		}
+	}
+	for _, pattern := range c.only {
+		if pattern.matchPrefix(path) {
+			return filterDescend
+		}
+	}
+	return filterSkip
+}
+
+// emit returns d if its path is selected by the ignore and only filters. Moves
+// are filtered by the path they originate from.
+func (c *comparer) emit(d Diff) []Diff {
+	path := d.Path
+	if d.Type == DiffMoved {
+		path = d.From
+	}
+	if c.filter(path) != filterInclude {
+		return nil
+	}
+	return []Diff{d}
+}
+
+func (c *comparer) added(path Path, value interface{}, order *keyOrder) []Diff {
+	if c.filter(path) == filterDescend {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			return c.maps(map[string]interface{}{}, v, path, nil, order)
+		case []interface{}:
+			return c.slices(nil, v, path, nil, order)
+		}
+		return nil
+	}
+	return c.emit(Diff{Type: DiffAdded, Path: path, NewValue: value, order: order})
+}
+
+func (c *comparer) removed(path Path, value interface{}, order *keyOrder) []Diff {
+	if c.filter(path) == filterDescend {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			return c.maps(v, map[string]interface{}{}, path, order, nil)
+		case []interface{}:
+			return c.slices(v, nil, path, order, nil)
+		}
+		return nil
+	}
+	return c.emit(Diff{Type: DiffRemoved, Path: path, OldValue: value})
+}
@@ -0,0 +1,68 @@
+package compare
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// gitScheme prefixes sources read from the local git repository, e.g.
+// "git:HEAD~1:config/app.yaml". As in git, the path is relative to the root
+// of the repository unless it starts with "./" or "../".
+const gitScheme = "git:"
+
+func readGitBlob(source string, config RemoteConfig) ([]byte, error) {
+	spec := strings.TrimPrefix(source, gitScheme)
+	rev, path, found := strings.Cut(spec, ":")
+	if !found || rev == "" || path == "" {
+		return nil, fmt.Errorf("invalid git source %q: expected git:<rev>:<path>", source)
+	}
+	if strings.HasPrefix(rev, "-") {
+		return nil, fmt.Errorf("invalid git revision %q", rev)
+	}
+
+	ctx := context.Background()
+	if config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.Timeout)
+		defer cancel()
+	}
+
+	object := rev + ":" + path
+	var stderr bytes.Buffer
+	size := exec.CommandContext(ctx, "git", "cat-file", "-s", object)
+	size.Stderr = &stderr
+	out, err := size.Output()
+	if err != nil {
+		return nil, gitError(object, err, &stderr)
+	}
+	var n int64
+	if _, err := fmt.Sscan(string(out), &n); err != nil {
+		return nil, fmt.Errorf("unexpected git cat-file output %q", out)
+	}
+	if config.MaxFileSize > 0 && n > config.MaxFileSize {
+		return nil, fmt.Errorf("file size %d exceeds limit of %d bytes", n, config.MaxFileSize)
+	}
+
+	stderr.Reset()
+	blob := exec.CommandContext(ctx, "git", "cat-file", "blob", object)
+	blob.Stderr = &stderr
+	content, err := blob.Output()
+	if err != nil {
+		return nil, gitError(object, err, &stderr)
+	}
+	return content, nil
+}
+
+func gitError(object string, err error, stderr *bytes.Buffer) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("failed to read %s from git: %s", object, msg)
+		}
+	}
+	return fmt.Errorf("failed to read %s from git: %v", object, err)
+}
@@ -2,6 +2,7 @@ package compare
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclparse"
@@ -31,91 +32,82 @@ type HCLComparator struct {
 	HCLValidator
 }
 
-func (h *HCLComparator) Compare(file1, file2 string, ignoreCase bool, config RemoteConfig) ([]Diff, error) {
-	data1, err := readFileContent(file1, config)
-	if err != nil {
-		return nil, fmt.Errorf("error reading first file: %w", err)
-	}
-
-	data2, err := readFileContent(file2, config)
-	if err != nil {
-		return nil, fmt.Errorf("error reading second file: %w", err)
-	}
+func (h *HCLComparator) Compare(doc1, doc2 *Document, opts Options) ([]Diff, error) {
+	return compareTrees(doc1, doc2, opts), nil
+}
 
-	parser := hclparse.NewParser()
-	f1, diags := parser.ParseHCL(data1, file1)
-	if diags.HasErrors() {
-		return nil, fmt.Errorf("HCL parsing error: %w", diags.Errs()[0])
-	}
+// Add this missing method
+func (h *HCLComparator) Validator() FileValidator {
+	return &h.HCLValidator
+}
 
-	f2, diags := parser.ParseHCL(data2, file2)
+func (h *HCLComparator) parse(data []byte, name string) (interface{}, *keyOrder, error) {
+	file, diags := hclparse.NewParser().ParseHCL(data, name)
 	if diags.HasErrors() {
-		return nil, fmt.Errorf("HCL parsing error: %w", diags.Errs()[0])
+		return nil, nil, fmt.Errorf("HCL parsing error: %w", diags.Errs()[0])
 	}
 
-	obj1, err := hclToMap(f1)
+	obj, order, err := hclToMap(file)
 	if err != nil {
-		return nil, fmt.Errorf("HCL conversion error: %w", err)
+		return nil, nil, fmt.Errorf("HCL conversion error: %w", err)
 	}
-
-	obj2, err := hclToMap(f2)
-	if err != nil {
-		return nil, fmt.Errorf("HCL conversion error: %w", err)
-	}
-
-	return CompareValues(obj1, obj2, "", ignoreCase), nil
-}
-
-// Add this missing method
-func (h *HCLComparator) Validator() FileValidator {
-	return &h.HCLValidator
+	return obj, order, nil
 }
 
-func hclToMap(file *hcl.File) (map[string]interface{}, error) {
+func hclToMap(file *hcl.File) (map[string]interface{}, *keyOrder, error) {
 	val, diags := file.Body.Content(&hcl.BodySchema{
 		Attributes: []hcl.AttributeSchema{},
 		Blocks:     []hcl.BlockHeaderSchema{},
 	})
 	if diags.HasErrors() {
-		return nil, diags
+		return nil, nil, diags
 	}
 
 	result := make(map[string]interface{})
+	order := mapOrder(hclSourceOrder(val))
 	for _, attr := range val.Attributes {
 		ctyVal, diags := attr.Expr.Value(nil)
 		if diags.HasErrors() {
-			return nil, diags
+			return nil, nil, diags
 		}
 
 		goVal, err := ctyToGo(ctyVal)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		result[attr.Name] = goVal
 	}
 
 	for _, block := range val.Blocks {
-		blockMap, err := hclBlockToMap(block)
+		blockMap, blockOrder, err := hclBlockToMap(block)
 		if err != nil {
-			return nil, err
-		}
-
-		if existing, exists := result[block.Type]; exists {
-			if slice, ok := existing.([]interface{}); ok {
-				result[block.Type] = append(slice, blockMap)
-			} else {
-				result[block.Type] = []interface{}{existing, blockMap}
-			}
-		} else {
-			result[block.Type] = blockMap
+			return nil, nil, err
 		}
+		addHCLBlock(result, order, block.Type, blockMap, blockOrder)
 	}
+	return result, order, nil
+}
 
-	return result, nil
+// addHCLBlock adds a block to the map of its parent body. Repeated blocks of
+// the same type are collected into a list.
+func addHCLBlock(result map[string]interface{}, order *keyOrder, blockType string, blockMap map[string]interface{}, blockOrder *keyOrder) {
+	existing, exists := result[blockType]
+	if !exists {
+		result[blockType] = blockMap
+		order.setField(blockType, blockOrder)
+		return
+	}
+	if slice, ok := existing.([]interface{}); ok {
+		result[blockType] = append(slice, blockMap)
+		order.fields[blockType].elems = append(order.fields[blockType].elems, blockOrder)
+	} else {
+		result[blockType] = []interface{}{existing, blockMap}
+		order.setField(blockType, &keyOrder{elems: []*keyOrder{order.field(blockType), blockOrder}})
+	}
 }
 
-func hclBlockToMap(block *hcl.Block) (map[string]interface{}, error) {
+func hclBlockToMap(block *hcl.Block) (map[string]interface{}, *keyOrder, error) {
 	result := make(map[string]interface{})
 	if len(block.Labels) > 0 {
 		result["__labels__"] = block.Labels
@@ -126,41 +118,61 @@ func hclBlockToMap(block *hcl.Block) (map[string]interface{}, error) {
 		Blocks:     []hcl.BlockHeaderSchema{},
 	})
 	if diags.HasErrors() {
-		return nil, diags
+		return nil, nil, diags
 	}
 
+	order := mapOrder(append([]string{"__labels__"}, hclSourceOrder(val)...))
 	for _, attr := range val.Attributes {
 		ctyVal, diags := attr.Expr.Value(nil)
 		if diags.HasErrors() {
-			return nil, diags
+			return nil, nil, diags
 		}
 
 		goVal, err := ctyToGo(ctyVal)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		result[attr.Name] = goVal
 	}
 
 	for _, nestedBlock := range val.Blocks {
-		nestedMap, err := hclBlockToMap(nestedBlock)
+		nestedMap, nestedOrder, err := hclBlockToMap(nestedBlock)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
+		addHCLBlock(result, order, nestedBlock.Type, nestedMap, nestedOrder)
+	}
+	return result, order, nil
+}
 
-		if existing, exists := result[nestedBlock.Type]; exists {
-			if slice, ok := existing.([]interface{}); ok {
-				result[nestedBlock.Type] = append(slice, nestedMap)
-			} else {
-				result[nestedBlock.Type] = []interface{}{existing, nestedMap}
-			}
-		} else {
-			result[nestedBlock.Type] = nestedMap
-		}
+// hclSourceOrder returns attribute names and block types in the order they
+// first appear in the source.
+func hclSourceOrder(content *hcl.BodyContent) []string {
+	type entry struct {
+		name string
+		pos  int
+	}
+	var entries []entry
+	for name, attr := range content.Attributes {
+		entries = append(entries, entry{name, attr.Range.Start.Byte})
 	}
+	for _, block := range content.Blocks {
+		entries = append(entries, entry{block.Type, block.DefRange.Start.Byte})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].pos < entries[j].pos
+	})
 
-	return result, nil
+	var keys []string
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		if !seen[e.name] {
+			seen[e.name] = true
+			keys = append(keys, e.name)
+		}
+	}
+	return keys
 }
 
 func ctyToGo(val cty.Value) (interface{}, error) {
@@ -1,10 +1,6 @@
 package compare
 
-import (
-	"fmt"
-
-	"gopkg.in/ini.v1"
-)
+import "gopkg.in/ini.v1"
 
 type INIValidator struct{}
 
@@ -25,39 +21,26 @@ type INIComparator struct {
 	INIValidator
 }
 
-func (i *INIComparator) Compare(file1, file2 string, ignoreCase bool, config RemoteConfig) ([]Diff, error) {
-	data1, err := readFileContent(file1, config)
-	if err != nil {
-		return nil, fmt.Errorf("error reading first file: %w", err)
-	}
-
-	data2, err := readFileContent(file2, config)
-	if err != nil {
-		return nil, fmt.Errorf("error reading second file: %w", err)
-	}
-
-	cfg1, err := ini.Load(data1)
-	if err != nil {
-		return nil, fmt.Errorf("error parsing first INI file: %w", err)
-	}
-
-	cfg2, err := ini.Load(data2)
-	if err != nil {
-		return nil, fmt.Errorf("error parsing second INI file: %w", err)
-	}
-
-	obj1 := iniToMap(cfg1)
-	obj2 := iniToMap(cfg2)
-
-	return CompareValues(obj1, obj2, "", ignoreCase), nil
+func (i *INIComparator) Compare(doc1, doc2 *Document, opts Options) ([]Diff, error) {
+	return compareTrees(doc1, doc2, opts), nil
 }
 
 func (i *INIComparator) Validator() FileValidator {
 	return &i.INIValidator
 }
 
-func iniToMap(cfg *ini.File) map[string]interface{} {
+func (i *INIComparator) parse(data []byte, name string) (interface{}, *keyOrder, error) {
+	cfg, err := ini.Load(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	result, order := iniToMap(cfg)
+	return result, order, nil
+}
+
+func iniToMap(cfg *ini.File) (map[string]interface{}, *keyOrder) {
 	result := make(map[string]interface{})
+	order := mapOrder(nil)
 	for _, section := range cfg.Sections() {
 		if section.Name() == "DEFAULT" {
 			continue
@@ -67,6 +50,8 @@ func iniToMap(cfg *ini.File) map[string]interface{} {
 			sectionMap[key.Name()] = key.Value()
 		}
 		result[section.Name()] = sectionMap
+		order.names = append(order.names, section.Name())
+		order.setField(section.Name(), mapOrder(section.KeyStrings()))
 	}
-	return result
+	return result, order
 }
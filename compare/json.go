@@ -1,8 +1,10 @@
 package compare
 
 import (
+	"bytes"
 	"encoding/json"
-	"fmt"
+	"errors"
+	"io"
 )
 
 type JSONValidator struct{}
@@ -24,28 +26,80 @@ type JSONComparator struct {
 	JSONValidator
 }
 
-func (j *JSONComparator) Compare(file1, file2 string, ignoreCase bool, config RemoteConfig) ([]Diff, error) {
-	data1, err := readFileContent(file1, config)
+func (j *JSONComparator) Compare(doc1, doc2 *Document, opts Options) ([]Diff, error) {
+	return compareTrees(doc1, doc2, opts), nil
+}
+
+func (j *JSONComparator) Validator() FileValidator {
+	return &j.JSONValidator
+}
+
+func (j *JSONComparator) parse(data []byte, name string) (interface{}, *keyOrder, error) {
+	return decodeJSON(data)
+}
+
+// decodeJSON decodes a JSON document like json.Unmarshal into an interface{},
+// along with the key order of every object. Numbers are kept as json.Number
+// so large integers survive without rounding.
+func decodeJSON(data []byte) (interface{}, *keyOrder, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	value, order, err := decodeJSONValue(dec)
 	if err != nil {
-		return nil, fmt.Errorf("error reading first file: %w", err)
+		return nil, nil, err
 	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, nil, errors.New("invalid data after top-level value")
+	}
+	return value, order, nil
+}
 
-	data2, err := readFileContent(file2, config)
+func decodeJSONValue(dec *json.Decoder) (interface{}, *keyOrder, error) {
+	tok, err := dec.Token()
 	if err != nil {
-		return nil, fmt.Errorf("error reading second file: %w", err)
+		return nil, nil, err
 	}
 
-	var obj1, obj2 interface{}
-	if err := json.Unmarshal(data1, &obj1); err != nil {
-		return nil, fmt.Errorf("error parsing first file: %w", err)
+	switch tok {
+	case json.Delim('{'):
+		obj := make(map[string]interface{})
+		order := mapOrder(nil)
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, nil, err
+			}
+			key := keyTok.(string)
+			value, child, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, nil, err
+			}
+			if _, exists := obj[key]; !exists {
+				order.names = append(order.names, key)
+			}
+			obj[key] = value
+			order.setField(key, child)
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, nil, err
+		}
+		return obj, order, nil
+	case json.Delim('['):
+		list := []interface{}{}
+		order := &keyOrder{}
+		for dec.More() {
+			value, child, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, nil, err
+			}
+			list = append(list, value)
+			order.elems = append(order.elems, child)
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, nil, err
+		}
+		return list, order, nil
+	default:
+		return tok, nil, nil
 	}
-	if err := json.Unmarshal(data2, &obj2); err != nil {
-		return nil, fmt.Errorf("error parsing second file: %w", err)
-	}
-
-	return CompareValues(obj1, obj2, "", ignoreCase), nil
-}
-
-func (j *JSONComparator) Validator() FileValidator {
-	return &j.JSONValidator
 }
@@ -0,0 +1,150 @@
+package compare
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ListKey pairs the elements of the lists matching Path, such as
+// "spec.containers[*]", by the values of Fields instead of by position.
+type ListKey struct {
+	Path   string
+	Fields []string
+}
+
+// ParseListKey parses a "path=field[,field...]" specification as accepted by
+// the --list-key flag.
+func ParseListKey(spec string) (ListKey, error) {
+	idx := strings.LastIndex(spec, "=")
+	if idx <= 0 || idx == len(spec)-1 {
+		return ListKey{}, fmt.Errorf("invalid list key %q: expected path=field[,field...]", spec)
+	}
+
+	var fields []string
+	for _, field := range strings.Split(spec[idx+1:], ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			return ListKey{}, fmt.Errorf("invalid list key %q: empty field name", spec)
+		}
+		fields = append(fields, field)
+	}
+	return ListKey{Path: spec[:idx], Fields: fields}, nil
+}
+
+type compiledListKey struct {
+	pattern pathPattern
+	fields  []string
+}
+
+// compileListPattern accepts both "items" and "items[*]" as naming the list.
+func compileListPattern(path string) (pathPattern, error) {
+	pattern, err := compilePattern(path)
+	if err != nil {
+		return nil, err
+	}
+	if n := len(pattern); n > 0 && pattern[n-1].wildcard && pattern[n-1].segment.Element {
+		pattern = pattern[:n-1]
+	}
+	return pattern, nil
+}
+
+func (c *comparer) listKeyFields(path Path) []string {
+	for _, key := range c.listKeys {
+		if key.pattern.match(path) {
+			return key.fields
+		}
+	}
+	return nil
+}
+
+// elementKeys renders the identity of every element as a selector of
+// "field=value" pairs.
+// It fails when an element is not a map, lacks a key field, or when two
+// elements share the same identity, in which case callers fall back to
+// positional comparison.
+func elementKeys(list []interface{}, fields []string) ([]string, map[string]int, bool) {
+	keys := make([]string, len(list))
+	index := make(map[string]int, len(list))
+	for i, elem := range list {
+		m, ok := elem.(map[string]interface{})
+		if !ok {
+			return nil, nil, false
+		}
+		values := make([]interface{}, len(fields))
+		for f, field := range fields {
+			value, exists := m[field]
+			if !exists {
+				return nil, nil, false
+			}
+			values[f] = value
+		}
+		key := formatSelector(fields, values)
+		if _, dup := index[key]; dup {
+			return nil, nil, false
+		}
+		keys[i] = key
+		index[key] = i
+	}
+	return keys, index, true
+}
+
+// keyedSlices matches elements by their key fields. Elements whose relative
+// order changed are reported as moved, from their keyed path to their new
+// index, unless the list is unordered.
+func (c *comparer) keyedSlices(a, b []interface{}, path Path, fields []string, orderA, orderB *keyOrder) ([]Diff, bool) {
+	aKeys, aIndex, ok := elementKeys(a, fields)
+	if !ok {
+		return nil, false
+	}
+	bKeys, bIndex, ok := elementKeys(b, fields)
+	if !ok {
+		return nil, false
+	}
+
+	var aOrder, bOrder []string
+	for _, key := range aKeys {
+		if _, exists := bIndex[key]; exists {
+			aOrder = append(aOrder, key)
+		}
+	}
+	for _, key := range bKeys {
+		if _, exists := aIndex[key]; exists {
+			bOrder = append(bOrder, key)
+		}
+	}
+	inOrder := make(map[string]bool)
+	for _, e := range myersDiff(len(aOrder), len(bOrder), func(i, j int) bool {
+		return aOrder[i] == bOrder[j]
+	}) {
+		if e.Kind == editEqual {
+			inOrder[aOrder[e.AIndex]] = true
+		}
+	}
+
+	var diffs []Diff
+	for i, key := range aKeys {
+		elemPath := path.KeyedElem(i, key)
+		j, exists := bIndex[key]
+		if !exists {
+			diffs = append(diffs, c.removed(elemPath, a[i], orderA.elem(i))...)
+			continue
+		}
+		diffs = append(diffs, c.values(a[i], b[j], elemPath, orderA.elem(i), orderB.elem(j))...)
+		if !inOrder[key] && !c.isUnordered(path) {
+			diffs = append(diffs, c.emit(Diff{
+				Type:     DiffMoved,
+				Path:     path.Elem(j),
+				From:     elemPath,
+				OldValue: a[i],
+				NewValue: b[j],
+				order:    orderB.elem(j),
+			})...)
+		}
+	}
+	for j, key := range bKeys {
+		if _, exists := aIndex[key]; !exists {
+			diffs = append(diffs, c.added(path.KeyedElem(j, key), b[j], orderB.elem(j))...)
+		}
+	}
+	return diffs, true
+}
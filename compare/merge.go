@@ -0,0 +1,441 @@
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Conflict is a location changed in different ways by both sides of a
+// three-way merge. The Absent flags mark sides on which the value does not
+// exist, e.g. because it was removed.
+type Conflict struct {
+	Path         Path        `json:"path"`
+	Base         interface{} `json:"base,omitempty"`
+	Ours         interface{} `json:"ours,omitempty"`
+	Theirs       interface{} `json:"theirs,omitempty"`
+	BaseAbsent   bool        `json:"base_absent,omitempty"`
+	OursAbsent   bool        `json:"ours_absent,omitempty"`
+	TheirsAbsent bool        `json:"theirs_absent,omitempty"`
+}
+
+// MarshalJSON adds the RFC 6901 JSON Pointer of the conflict's path.
+func (c Conflict) MarshalJSON() ([]byte, error) {
+	type conflict Conflict
+	return json.Marshal(struct {
+		conflict
+		Pointer string `json:"pointer"`
+	}{conflict(c), c.Path.Pointer()})
+}
+
+// Merge performs a three-way merge of ours and theirs, two documents derived
+// from base. Changes made on only one side, or identically on both, are
+// merged; the other overlapping changes are reported as conflicts and resolved
+// in favour of ours. Lists whose elements are added, removed or reordered on
+// both sides conflict as a whole unless both sides end up equal.
+//
+// The changes are found with CompareValues, so options that hide differences,
+// such as Ignore, Only, IgnoreCase and the tolerances, leave the hidden
+// changes out of the result.
+func Merge(base, ours, theirs interface{}, opts Options) (interface{}, []Conflict, error) {
+	m, err := newMerge(base, ours, theirs, [3]*keyOrder{}, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	merged, _, err := m.resolve(copyValue(base), nil, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	return merged, m.conflicts, nil
+}
+
+// MergeFiles merges three files of the same format and returns the merged
+// document encoded in that format, which must be json, yaml or toml.
+func MergeFiles(base, ours, theirs, format string, opts Options, config RemoteConfig) ([]byte, []Conflict, error) {
+	docs, err := loadMergeDocuments(base, ours, theirs, format, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	return MergeDocuments(docs[0], docs[1], docs[2], opts)
+}
+
+// MergeDocuments is MergeFiles for parsed documents, which must share their
+// format.
+func MergeDocuments(base, ours, theirs *Document, opts Options) ([]byte, []Conflict, error) {
+	f, err := newFileMerge(base, ours, theirs, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	merged, err := f.encode(true)
+	if err != nil {
+		return nil, nil, err
+	}
+	return merged, f.conflicts, nil
+}
+
+// ConflictMarkers describes the markers surrounding conflicting lines.
+type ConflictMarkers struct {
+	// Size is the length of each marker, 7 when zero.
+	Size   int
+	Ours   string
+	Theirs string
+}
+
+// MergeFilesWithMarkers is like MergeFiles, but instead of resolving
+// conflicts in favour of ours it writes both versions of the lines that
+// differ between the two resolutions, in the style of git:
+//
+//	<<<<<<< ours
+//	version: 2
+//	=======
+//	version: 3
+//	>>>>>>> theirs
+//
+// The result is not a valid document while conflicts remain.
+func MergeFilesWithMarkers(base, ours, theirs, format string, opts Options, config RemoteConfig, markers ConflictMarkers) ([]byte, []Conflict, error) {
+	docs, err := loadMergeDocuments(base, ours, theirs, format, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := newFileMerge(docs[0], docs[1], docs[2], opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	oursMerged, err := f.encode(true)
+	if err != nil || len(f.conflicts) == 0 {
+		return oursMerged, f.conflicts, err
+	}
+	theirsMerged, err := f.encode(false)
+	if err != nil {
+		return nil, nil, err
+	}
+	return markConflicts(oursMerged, theirsMerged, markers), f.conflicts, nil
+}
+
+// fileMerge is a merge of parsed files. The base document is kept encoded so
+// that it can be resolved in favour of either side.
+type fileMerge struct {
+	*merge
+	parser   documentParser
+	name     string
+	baseData []byte
+	format   string
+}
+
+func loadMergeDocuments(base, ours, theirs, format string, config RemoteConfig) ([3]*Document, error) {
+	var docs [3]*Document
+	for i, source := range []string{base, ours, theirs} {
+		doc, err := LoadDocument(source, format, config, ParseOptions{})
+		if err != nil {
+			return docs, err
+		}
+		docs[i] = doc
+	}
+	return docs, nil
+}
+
+func newFileMerge(base, ours, theirs *Document, opts Options) (*fileMerge, error) {
+	for _, doc := range []*Document{ours, theirs} {
+		if !strings.EqualFold(doc.Format, base.Format) {
+			return nil, fmt.Errorf("cannot merge %s in %s with %s in %s", doc.Source, doc.Format, base.Source, base.Format)
+		}
+	}
+	comparator, err := newParser(base.Format, base.Source, ParseOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	f := &fileMerge{
+		parser:   comparator.(documentParser),
+		name:     base.Source,
+		baseData: base.Content,
+		format:   base.Format,
+	}
+	f.merge, err = newMerge(base.Tree, ours.Tree, theirs.Tree, [3]*keyOrder{base.order, ours.order, theirs.order}, opts)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *fileMerge) encode(ours bool) ([]byte, error) {
+	doc, order, err := f.parser.parse(f.baseData, f.name)
+	if err != nil {
+		return nil, err
+	}
+	merged, order, err := f.resolve(doc, order, ours)
+	if err != nil {
+		return nil, err
+	}
+	return encodeDocument(merged, f.format, order)
+}
+
+// markConflicts interleaves two renderings of a document, surrounding the
+// runs of lines that differ with conflict markers.
+func markConflicts(ours, theirs []byte, markers ConflictMarkers) []byte {
+	size := markers.Size
+	if size <= 0 {
+		size = 7
+	}
+	label := func(marker byte, name string) string {
+		line := strings.Repeat(string(marker), size)
+		if name != "" {
+			line += " " + name
+		}
+		return line + "\n"
+	}
+
+	a := strings.SplitAfter(string(ours), "\n")
+	b := strings.SplitAfter(string(theirs), "\n")
+	edits := myersDiff(len(a), len(b), func(i, j int) bool { return a[i] == b[j] })
+
+	var sb strings.Builder
+	for start := 0; start < len(edits); {
+		if edits[start].Kind == editEqual {
+			sb.WriteString(a[edits[start].AIndex])
+			start++
+			continue
+		}
+		end := start
+		var oursLines, theirsLines []string
+		for ; end < len(edits) && edits[end].Kind != editEqual; end++ {
+			if e := edits[end]; e.Kind == editDelete {
+				oursLines = append(oursLines, a[e.AIndex])
+			} else {
+				theirsLines = append(theirsLines, b[e.BIndex])
+			}
+		}
+		sb.WriteString(label('<', markers.Ours))
+		writeLines(&sb, oursLines)
+		sb.WriteString(label('=', ""))
+		writeLines(&sb, theirsLines)
+		sb.WriteString(label('>', markers.Theirs))
+		start = end
+	}
+	return []byte(sb.String())
+}
+
+func writeLines(sb *strings.Builder, lines []string) {
+	for _, line := range lines {
+		sb.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			sb.WriteString("\n")
+		}
+	}
+}
+
+type merge struct {
+	c          *comparer
+	base       interface{}
+	ours       []Diff
+	theirs     []Diff
+	duplicates map[int]bool // indexes into theirs of changes also made by ours
+	conflicts  []Conflict
+	resolved   []Path // conflict locations, including those where both sides agree
+}
+
+// newMerge finds the changes made by both sides. orders holds the key order of
+// base, ours and theirs, which the merged values keep.
+func newMerge(base, ours, theirs interface{}, orders [3]*keyOrder, opts Options) (*merge, error) {
+	c, err := newComparer(opts)
+	if err != nil {
+		return nil, err
+	}
+	m := &merge{
+		c:          c,
+		base:       base,
+		ours:       compareOrdered(base, ours, orders[0], orders[1], opts),
+		theirs:     compareOrdered(base, theirs, orders[0], orders[2], opts),
+		duplicates: make(map[int]bool),
+	}
+
+	var overlaps []Path
+	for j, t := range m.theirs {
+		for _, o := range m.ours {
+			if m.sameChange(o, t) {
+				m.duplicates[j] = true
+				break
+			}
+		}
+	}
+	for j, t := range m.theirs {
+		if m.duplicates[j] {
+			continue
+		}
+		for _, o := range m.ours {
+			if at, ok := overlap(o, t); ok {
+				overlaps = append(overlaps, at)
+			}
+		}
+	}
+
+	sort.SliceStable(overlaps, func(i, j int) bool {
+		return comparePaths(overlaps[i], overlaps[j]) < 0
+	})
+	for _, at := range overlaps {
+		if n := len(m.resolved); n > 0 && hasPathPrefix(at, m.resolved[n-1]) {
+			continue
+		}
+		m.resolved = append(m.resolved, at)
+
+		conflict := Conflict{Path: at}
+		var present bool
+		conflict.Base, present = lookupPath(base, at)
+		conflict.BaseAbsent = !present
+		if conflict.Ours, present, err = sideValue(base, m.ours, at); err != nil {
+			return nil, err
+		}
+		conflict.OursAbsent = !present
+		if conflict.Theirs, present, err = sideValue(base, m.theirs, at); err != nil {
+			return nil, err
+		}
+		conflict.TheirsAbsent = !present
+
+		if conflict.OursAbsent && conflict.TheirsAbsent ||
+			!conflict.OursAbsent && !conflict.TheirsAbsent && c.equal(conflict.Ours, conflict.Theirs) {
+			continue
+		}
+		m.conflicts = append(m.conflicts, conflict)
+	}
+	return m, nil
+}
+
+// resolve applies the merged changes to doc and its key order, taking the side
+// selected by ours at every conflict.
+func (m *merge) resolve(doc interface{}, order *keyOrder, ours bool) (interface{}, *keyOrder, error) {
+	preferred, other := m.ours, m.theirs
+	if !ours {
+		preferred, other = m.theirs, m.ours
+	}
+
+	diffs := append([]Diff(nil), preferred...)
+	for j, d := range other {
+		if ours && m.duplicates[j] {
+			continue
+		}
+		if !ours && m.isDuplicateOf(d) {
+			continue
+		}
+		if !m.touchesConflict(d) {
+			diffs = append(diffs, d)
+		}
+	}
+	return applyPatch(doc, order, JSONPatch(diffs))
+}
+
+func (m *merge) isDuplicateOf(o Diff) bool {
+	for j, t := range m.theirs {
+		if m.duplicates[j] && m.sameChange(o, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// touchesConflict reports whether a change affects a conflict location. The
+// paths of added elements and move targets are indexes into the changed
+// list rather than the base one, so only the location a change claims and
+// the origin of a move are compared with the conflicts.
+func (m *merge) touchesConflict(d Diff) bool {
+	claimed, hasClaim := claim(d)
+	for _, at := range m.resolved {
+		if hasClaim && hasPathPrefix(claimed, at) || d.From != nil && hasPathPrefix(d.From, at) {
+			return true
+		}
+		if list, ok := structuralList(d); ok && hasPathPrefix(list, at) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *merge) sameChange(a, b Diff) bool {
+	return a.Type == b.Type &&
+		comparePaths(a.Path, b.Path) == 0 &&
+		comparePaths(a.From, b.From) == 0 &&
+		m.c.equal(a.NewValue, b.NewValue)
+}
+
+// overlap reports the location at which two changes from different sides
+// interfere: one changes a value containing the other's, or both add, remove
+// or reorder elements of the same list.
+func overlap(a, b Diff) (Path, bool) {
+	aClaim, aHasClaim := claim(a)
+	bClaim, bHasClaim := claim(b)
+	aList, aStructural := structuralList(a)
+	bList, bStructural := structuralList(b)
+
+	switch {
+	case aHasClaim && bHasClaim && hasPathPrefix(bClaim, aClaim):
+		return aClaim, true
+	case aHasClaim && bHasClaim && hasPathPrefix(aClaim, bClaim):
+		return bClaim, true
+	case aStructural && bStructural && comparePaths(aList, bList) == 0:
+		return aList, true
+	case aStructural && bHasClaim && hasPathPrefix(aList, bClaim):
+		return bClaim, true
+	case bStructural && aHasClaim && hasPathPrefix(bList, aClaim):
+		return aClaim, true
+	}
+	return nil, false
+}
+
+// claim returns the location in the base document whose value a change
+// replaces. Elements added to or moved within a list replace none.
+func claim(d Diff) (Path, bool) {
+	if _, ok := structuralList(d); ok && d.Type != DiffRemoved {
+		return nil, false
+	}
+	return d.Path, true
+}
+
+// structuralList returns the list whose elements a change adds, removes or
+// reorders.
+func structuralList(d Diff) (Path, bool) {
+	if len(d.Path) == 0 || !d.Path[len(d.Path)-1].Element || d.Type == DiffModified {
+		return nil, false
+	}
+	return d.Path[:len(d.Path)-1], true
+}
+
+// sideValue reconstructs the value one side holds at a location of the base
+// document from that side's changes.
+func sideValue(base interface{}, diffs []Diff, at Path) (interface{}, bool, error) {
+	var nested []Diff
+	for _, d := range diffs {
+		if claimed, ok := claim(d); ok && comparePaths(claimed, at) == 0 {
+			if d.Type == DiffRemoved {
+				return nil, false, nil
+			}
+			return d.NewValue, true, nil
+		}
+		if len(d.Path) > len(at) && hasPathPrefix(d.Path, at) {
+			rebased := d
+			rebased.Path = d.Path[len(at):]
+			if d.From != nil {
+				rebased.From = d.From[len(at):]
+			}
+			nested = append(nested, rebased)
+		}
+	}
+
+	value, ok := lookupPath(base, at)
+	if !ok {
+		return nil, false, nil
+	}
+	value, err := ApplyPatch(copyValue(value), JSONPatch(nested))
+	return value, true, err
+}
+
+func hasPathPrefix(path, prefix Path) bool {
+	if len(path) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if compareSegments(path[i], prefix[i]) != 0 {
+			return false
+		}
+	}
+	return true
+}
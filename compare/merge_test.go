@@ -0,0 +1,103 @@
+package compare
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	type conflict struct {
+		path                                 string
+		oursAbsent, theirsAbsent, baseAbsent bool
+	}
+	tests := []struct {
+		name               string
+		base, ours, theirs string
+		want               string
+		conflicts          []conflict
+	}{
+		{
+			name: "unchanged",
+			base: `{"a": 1}`, ours: `{"a": 1}`, theirs: `{"a": 1}`,
+			want: `{"a": 1}`,
+		},
+		{
+			name: "ours only",
+			base: `{"a": 1, "b": 2}`, ours: `{"a": 3, "b": 2, "c": 4}`, theirs: `{"a": 1, "b": 2}`,
+			want: `{"a": 3, "b": 2, "c": 4}`,
+		},
+		{
+			name: "theirs only",
+			base: `{"a": 1, "b": 2}`, ours: `{"a": 1, "b": 2}`, theirs: `{"a": 1}`,
+			want: `{"a": 1}`,
+		},
+		{
+			name: "disjoint changes",
+			base: `{"a": 1, "b": {"c": 2, "d": 3}}`,
+			ours: `{"a": 5, "b": {"c": 2, "d": 3}}`, theirs: `{"a": 1, "b": {"c": 2, "d": 6}, "e": 7}`,
+			want: `{"a": 5, "b": {"c": 2, "d": 6}, "e": 7}`,
+		},
+		{
+			name: "identical changes",
+			base: `{"a": 1}`, ours: `{"a": 2, "b": 3}`, theirs: `{"a": 2, "b": 3}`,
+			want: `{"a": 2, "b": 3}`,
+		},
+		{
+			name: "disjoint list element changes",
+			base: `{"l": [1, 2, 3]}`, ours: `{"l": [9, 2, 3]}`, theirs: `{"l": [1, 2, 8]}`,
+			want: `{"l": [9, 2, 8]}`,
+		},
+		{
+			name: "conflicting modifications",
+			base: `{"a": 1, "b": 1}`, ours: `{"a": 2, "b": 1}`, theirs: `{"a": 3, "b": 4}`,
+			want:      `{"a": 2, "b": 4}`,
+			conflicts: []conflict{{path: "a"}},
+		},
+		{
+			name: "conflicting additions",
+			base: `{}`, ours: `{"a": 1}`, theirs: `{"a": 2}`,
+			want:      `{"a": 1}`,
+			conflicts: []conflict{{path: "a", baseAbsent: true}},
+		},
+		{
+			name: "ours deletes, theirs modifies",
+			base: `{"a": {"b": 1}, "c": 1}`, ours: `{"c": 1}`, theirs: `{"a": {"b": 2}, "c": 1}`,
+			want:      `{"c": 1}`,
+			conflicts: []conflict{{path: "a", oursAbsent: true}},
+		},
+		{
+			name: "insertion at the index of a conflict",
+			base: `{"l": [2, 2]}`, ours: `{"l": [2, 1]}`, theirs: `{"l": [0, 1, 2, 0, 1]}`,
+			want:      `{"l": [0, 1, 2, 1, 1]}`,
+			conflicts: []conflict{{path: "l[1]"}},
+		},
+		{
+			name: "ours modifies, theirs deletes",
+			base: `{"a": 1, "c": 1}`, ours: `{"a": 2, "c": 1}`, theirs: `{"c": 1}`,
+			want:      `{"a": 2, "c": 1}`,
+			conflicts: []conflict{{path: "a", theirsAbsent: true}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := mustDecodeJSON(t, tt.base)
+			merged, conflicts, err := Merge(base, mustDecodeJSON(t, tt.ours), mustDecodeJSON(t, tt.theirs), Options{})
+			if err != nil {
+				t.Fatalf("Merge: %v", err)
+			}
+			if want := mustDecodeJSON(t, tt.want); !reflect.DeepEqual(merged, want) {
+				t.Errorf("merged %v, want %v", merged, want)
+			}
+			var got []conflict
+			for _, c := range conflicts {
+				got = append(got, conflict{c.Path.String(), c.OursAbsent, c.TheirsAbsent, c.BaseAbsent})
+			}
+			if !reflect.DeepEqual(got, tt.conflicts) {
+				t.Errorf("conflicts %+v, want %+v", got, tt.conflicts)
+			}
+			if !reflect.DeepEqual(base, mustDecodeJSON(t, tt.base)) {
+				t.Errorf("Merge modified the base document")
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package compare
+
+// MergePatch converts the result of comparing original with another document
+// into an RFC 7386 JSON Merge Patch. Since merge patches cannot address list
+// elements, any change inside a list replaces the whole list, and a change at
+// the root of a non-object document replaces the document. A merge patch
+// cannot set a value to null either: such changes become removals.
+func MergePatch(original interface{}, diffs []Diff) (interface{}, error) {
+	target, err := ApplyPatch(copyValue(original), JSONPatch(diffs))
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := original.(map[string]interface{}); !ok {
+		return target, nil
+	}
+	if _, ok := target.(map[string]interface{}); !ok {
+		return target, nil
+	}
+
+	patch := make(map[string]interface{})
+	for _, d := range diffs {
+		prefix := d.Path
+		for i, segment := range d.Path {
+			if segment.Element {
+				prefix = d.Path[:i]
+				break
+			}
+		}
+		if len(prefix) == 0 {
+			return target, nil
+		}
+		value, _ := lookupPath(target, prefix)
+		setMergeValue(patch, prefix, value)
+	}
+	return patch, nil
+}
+
+// lookupPath returns the value at path, or false if it does not exist.
+func lookupPath(doc interface{}, path Path) (interface{}, bool) {
+	for _, segment := range path {
+		switch v := doc.(type) {
+		case map[string]interface{}:
+			child, exists := v[segment.Key]
+			if segment.Element || !exists {
+				return nil, false
+			}
+			doc = child
+		case []interface{}:
+			if !segment.Element || segment.Index < 0 || segment.Index >= len(v) {
+				return nil, false
+			}
+			doc = v[segment.Index]
+		default:
+			return nil, false
+		}
+	}
+	return doc, true
+}
+
+func setMergeValue(patch map[string]interface{}, path Path, value interface{}) {
+	for _, segment := range path[:len(path)-1] {
+		child, exists := patch[segment.Key]
+		if !exists {
+			child = make(map[string]interface{})
+			patch[segment.Key] = child
+		}
+		next, ok := child.(map[string]interface{})
+		if !ok {
+			// An ancestor is already replaced as a whole.
+			return
+		}
+		patch = next
+	}
+	patch[path[len(path)-1].Key] = value
+}
@@ -0,0 +1,132 @@
+package compare
+
+type editKind int
+
+const (
+	editEqual editKind = iota
+	editDelete
+	editInsert
+)
+
+// edit is a single step of an edit script turning sequence a into sequence b.
+// AIndex is valid for equal and delete steps, BIndex for equal and insert steps.
+type edit struct {
+	Kind   editKind
+	AIndex int
+	BIndex int
+}
+
+// myersDiff computes a shortest edit script between two sequences of length n
+// and m using the linear-space variant of Myers' O(ND) algorithm. Elements are
+// compared through equal, which receives an index into each sequence.
+func myersDiff(n, m int, equal func(i, j int) bool) []edit {
+	d := &myers{equal: equal}
+	max := (n + m + 1) / 2
+	d.vf = make([]int, 2*max+3)
+	d.vb = make([]int, 2*max+3)
+	d.diff(0, n, 0, m)
+	return d.edits
+}
+
+type myers struct {
+	equal  func(i, j int) bool
+	vf, vb []int
+	edits  []edit
+}
+
+func (d *myers) diff(aLo, aHi, bLo, bHi int) {
+	for aLo < aHi && bLo < bHi && d.equal(aLo, bLo) {
+		d.edits = append(d.edits, edit{Kind: editEqual, AIndex: aLo, BIndex: bLo})
+		aLo++
+		bLo++
+	}
+
+	var suffix []edit
+	for aLo < aHi && bLo < bHi && d.equal(aHi-1, bHi-1) {
+		aHi--
+		bHi--
+		suffix = append(suffix, edit{Kind: editEqual, AIndex: aHi, BIndex: bHi})
+	}
+
+	switch {
+	case aLo == aHi:
+		for j := bLo; j < bHi; j++ {
+			d.edits = append(d.edits, edit{Kind: editInsert, AIndex: aLo, BIndex: j})
+		}
+	case bLo == bHi:
+		for i := aLo; i < aHi; i++ {
+			d.edits = append(d.edits, edit{Kind: editDelete, AIndex: i, BIndex: bLo})
+		}
+	default:
+		x1, y1, x2, y2 := d.middleSnake(aLo, aHi, bLo, bHi)
+		d.diff(aLo, aLo+x1, bLo, bLo+y1)
+		for i := 0; i < x2-x1; i++ {
+			d.edits = append(d.edits, edit{Kind: editEqual, AIndex: aLo + x1 + i, BIndex: bLo + y1 + i})
+		}
+		d.diff(aLo+x2, aHi, bLo+y2, bHi)
+	}
+
+	for i := len(suffix) - 1; i >= 0; i-- {
+		d.edits = append(d.edits, suffix[i])
+	}
+}
+
+// middleSnake finds the middle snake of an optimal path between the given
+// subsequences and returns its start and end points relative to aLo and bLo.
+func (d *myers) middleSnake(aLo, aHi, bLo, bHi int) (int, int, int, int) {
+	n, m := aHi-aLo, bHi-bLo
+	delta := n - m
+	odd := delta%2 != 0
+	max := (n + m + 1) / 2
+	off := max + 1
+	vf, vb := d.vf, d.vb
+	vf[off+1] = 0
+	vb[off+1] = 0
+
+	for k := 0; k <= max; k++ {
+		// Forward search.
+		for diag := -k; diag <= k; diag += 2 {
+			var x int
+			if diag == -k || (diag != k && vf[off+diag-1] < vf[off+diag+1]) {
+				x = vf[off+diag+1]
+			} else {
+				x = vf[off+diag-1] + 1
+			}
+			y := x - diag
+			x0, y0 := x, y
+			for x < n && y < m && d.equal(aLo+x, bLo+y) {
+				x++
+				y++
+			}
+			vf[off+diag] = x
+			back := delta - diag
+			if odd && back >= -(k-1) && back <= k-1 && x+vb[off+back] >= n {
+				return x0, y0, x, y
+			}
+		}
+
+		// Backward search, measured from the end of both subsequences.
+		for diag := -k; diag <= k; diag += 2 {
+			var x int
+			if diag == -k || (diag != k && vb[off+diag-1] < vb[off+diag+1]) {
+				x = vb[off+diag+1]
+			} else {
+				x = vb[off+diag-1] + 1
+			}
+			y := x - diag
+			x0, y0 := x, y
+			for x < n && y < m && d.equal(aLo+n-x-1, bLo+m-y-1) {
+				x++
+				y++
+			}
+			vb[off+diag] = x
+			fwd := delta - diag
+			if !odd && fwd >= -k && fwd <= k && x+vf[off+fwd] >= n {
+				return n - x, m - y, n - x0, m - y0
+			}
+		}
+	}
+
+	// Unreachable for valid input: an overlap always exists by d = max.
+	return 0, 0, 0, 0
+}
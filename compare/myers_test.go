@@ -0,0 +1,80 @@
+package compare
+
+import "testing"
+
+func TestMyersDiffMinimal(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+	}{
+		{"empty", "", ""},
+		{"insert all", "", "abc"},
+		{"delete all", "abc", ""},
+		{"equal", "abc", "abc"},
+		{"replace", "a", "b"},
+		{"prefix and suffix", "abcxyz", "abxxyz"},
+		{"myers paper", "abcabba", "cbabac"},
+		{"reversed", "abcd", "dcba"},
+		{"interleaved", "axbxcx", "abc"},
+		{"repeated", "aaaa", "aa"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			edits := myersDiff(len(tt.a), len(tt.b), func(i, j int) bool { return tt.a[i] == tt.b[j] })
+
+			var got []byte
+			changes := 0
+			ai, bi := 0, 0
+			for _, e := range edits {
+				switch e.Kind {
+				case editEqual:
+					if e.AIndex != ai || e.BIndex != bi || tt.a[e.AIndex] != tt.b[e.BIndex] {
+						t.Fatalf("bad equal step %+v at a[%d], b[%d]", e, ai, bi)
+					}
+					got = append(got, tt.a[e.AIndex])
+					ai++
+					bi++
+				case editDelete:
+					if e.AIndex != ai {
+						t.Fatalf("bad delete step %+v at a[%d]", e, ai)
+					}
+					ai++
+					changes++
+				case editInsert:
+					if e.BIndex != bi {
+						t.Fatalf("bad insert step %+v at b[%d]", e, bi)
+					}
+					got = append(got, tt.b[e.BIndex])
+					bi++
+					changes++
+				}
+			}
+			if ai != len(tt.a) || string(got) != tt.b {
+				t.Fatalf("edit script turns %q into %q, want %q", tt.a, got, tt.b)
+			}
+			if want := len(tt.a) + len(tt.b) - 2*lcsLength(tt.a, tt.b); changes != want {
+				t.Errorf("edit script has %d changes, want %d", changes, want)
+			}
+		})
+	}
+}
+
+func lcsLength(a, b string) int {
+	table := make([][]int, len(a)+1)
+	for i := range table {
+		table[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				table[i][j] = table[i+1][j+1] + 1
+			case table[i+1][j] > table[i][j+1]:
+				table[i][j] = table[i+1][j]
+			default:
+				table[i][j] = table[i][j+1]
+			}
+		}
+	}
+	return table[0][0]
+}
@@ -0,0 +1,71 @@
+package compare
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// normalizeTree returns a copy of a tree with the values that only some
+// formats can represent rewritten into the form others use, so that
+// documents in different formats can be compared: dates and times become
+// strings. Dates without a time, which YAML decodes as midnight UTC, are
+// written without one.
+func normalizeTree(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for key, value := range t {
+			m[key] = normalizeTree(value)
+		}
+		return m
+	case []interface{}:
+		list := make([]interface{}, len(t))
+		for i, value := range t {
+			list[i] = normalizeTree(value)
+		}
+		return list
+	case time.Time:
+		if t.Location() == time.UTC && t.Equal(t.Truncate(24*time.Hour)) {
+			return t.Format("2006-01-02")
+		}
+		return t.Format(time.RFC3339Nano)
+	case toml.LocalDate:
+		return t.String()
+	case toml.LocalTime:
+		return t.String()
+	case toml.LocalDateTime:
+		return t.String()
+	}
+	return v
+}
+
+// coercedEqual reports whether a string spells the number or boolean it is
+// compared with.
+func (c *comparer) coercedEqual(a, b interface{}) bool {
+	s, ok := a.(string)
+	other := b
+	if !ok {
+		if s, ok = b.(string); !ok {
+			return false
+		}
+		other = a
+	}
+	s = strings.TrimSpace(s)
+
+	switch o := other.(type) {
+	case bool:
+		parsed, err := strconv.ParseBool(s)
+		return err == nil && parsed == o
+	}
+	if isNumber(other) {
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			return false
+		}
+		return numbersEqual(json.Number(s), other, c.opts.AbsTolerance, c.opts.RelTolerance)
+	}
+	return false
+}
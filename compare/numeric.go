@@ -0,0 +1,116 @@
+package compare
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+)
+
+// toNumber converts any numeric value produced by the decoders into an exact
+// rational. Infinities and NaN are not representable and report false.
+func toNumber(v interface{}) (*big.Rat, bool) {
+	switch n := v.(type) {
+	case int:
+		return new(big.Rat).SetInt64(int64(n)), true
+	case int8:
+		return new(big.Rat).SetInt64(int64(n)), true
+	case int16:
+		return new(big.Rat).SetInt64(int64(n)), true
+	case int32:
+		return new(big.Rat).SetInt64(int64(n)), true
+	case int64:
+		return new(big.Rat).SetInt64(n), true
+	case uint:
+		return new(big.Rat).SetUint64(uint64(n)), true
+	case uint8:
+		return new(big.Rat).SetUint64(uint64(n)), true
+	case uint16:
+		return new(big.Rat).SetUint64(uint64(n)), true
+	case uint32:
+		return new(big.Rat).SetUint64(uint64(n)), true
+	case uint64:
+		return new(big.Rat).SetUint64(n), true
+	case float32:
+		return ratFromFloat(float64(n))
+	case float64:
+		return ratFromFloat(n)
+	case json.Number:
+		return new(big.Rat).SetString(string(n))
+	}
+	return nil, false
+}
+
+func ratFromFloat(f float64) (*big.Rat, bool) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, false
+	}
+	return new(big.Rat).SetFloat64(f), true
+}
+
+func isNumber(v interface{}) bool {
+	switch v.(type) {
+	case int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64,
+		float32, float64, json.Number:
+		return true
+	}
+	return false
+}
+
+// numbersEqual compares two numeric values of any kind by value. Without a
+// tolerance the comparison is exact, so large integers are never rounded.
+// NaN equals NaN and an infinity equals the infinity of the same sign, so that
+// a document compares equal to itself; neither is within any tolerance of a
+// finite number.
+func numbersEqual(a, b interface{}, absTol, relTol float64) bool {
+	ra, aOk := toNumber(a)
+	rb, bOk := toNumber(b)
+	if !aOk || !bOk {
+		fa, fb := toFloat(a), toFloat(b)
+		switch {
+		case math.IsNaN(fa) || math.IsNaN(fb):
+			return math.IsNaN(fa) && math.IsNaN(fb)
+		case math.IsInf(fa, 0) || math.IsInf(fb, 0):
+			return math.IsInf(fa, 1) && math.IsInf(fb, 1) || math.IsInf(fa, -1) && math.IsInf(fb, -1)
+		}
+		return fa == fb
+	}
+	if ra.Cmp(rb) == 0 {
+		return true
+	}
+	if absTol <= 0 && relTol <= 0 {
+		return false
+	}
+	if math.IsInf(absTol, 1) || math.IsInf(relTol, 1) {
+		return true
+	}
+
+	delta := new(big.Rat).Sub(ra, rb)
+	delta.Abs(delta)
+	if absTol > 0 && delta.Cmp(new(big.Rat).SetFloat64(absTol)) <= 0 {
+		return true
+	}
+	if !(relTol > 0) {
+		return false
+	}
+	magnitude := new(big.Rat).Abs(ra)
+	if bAbs := new(big.Rat).Abs(rb); bAbs.Cmp(magnitude) > 0 {
+		magnitude = bAbs
+	}
+	limit := new(big.Rat).Mul(magnitude, new(big.Rat).SetFloat64(relTol))
+	return delta.Cmp(limit) <= 0
+}
+
+func toFloat(v interface{}) float64 {
+	switch n := v.(type) {
+	case float32:
+		return float64(n)
+	case float64:
+		return n
+	}
+	if r, ok := toNumber(v); ok {
+		f, _ := r.Float64()
+		return f
+	}
+	return math.NaN()
+}
@@ -0,0 +1,41 @@
+package compare
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestNumbersEqual(t *testing.T) {
+	nan, inf := math.NaN(), math.Inf(1)
+	tests := []struct {
+		name           string
+		a, b           interface{}
+		absTol, relTol float64
+		want           bool
+	}{
+		{name: "int and float", a: 1, b: 1.0, want: true},
+		{name: "large integers", a: json.Number("9007199254740993"), b: int64(9007199254740992), want: false},
+		{name: "within absolute tolerance", a: 1.0, b: 1.05, absTol: 0.1, want: true},
+		{name: "outside relative tolerance", a: 100, b: 102, relTol: 0.01, want: false},
+		{name: "NaN equals NaN", a: nan, b: nan, want: true},
+		{name: "float32 NaN equals NaN", a: float32(nan), b: nan, want: true},
+		{name: "NaN and number", a: nan, b: 0, want: false},
+		{name: "NaN within infinite tolerance", a: nan, b: 1.0, absTol: inf, want: false},
+		{name: "infinity equals infinity", a: inf, b: inf, want: true},
+		{name: "negative infinity", a: math.Inf(-1), b: math.Inf(-1), want: true},
+		{name: "opposite infinities", a: inf, b: math.Inf(-1), want: false},
+		{name: "infinity and NaN", a: inf, b: nan, want: false},
+		{name: "infinity and large number", a: inf, b: math.MaxFloat64, relTol: 1, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := numbersEqual(tt.a, tt.b, tt.absTol, tt.relTol); got != tt.want {
+				t.Errorf("numbersEqual(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+			if got := numbersEqual(tt.b, tt.a, tt.absTol, tt.relTol); got != tt.want {
+				t.Errorf("numbersEqual(%v, %v) = %v, want %v", tt.b, tt.a, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package compare
+
+import "sort"
+
+// SortOrder selects the order in which differences are reported.
+type SortOrder string
+
+const (
+	// SortDocument follows the key order of the source documents where the
+	// parser preserves it, falling back to lexical order otherwise.
+	SortDocument SortOrder = "document"
+	// SortPath orders differences lexically by path.
+	SortPath SortOrder = "path"
+)
+
+// keyOrder records the source order of the keys of the maps of a parsed
+// tree. It mirrors the tree: the node of a map holds the map's keys and the
+// nodes of its values, the node of a list those of its elements. A nil node
+// records nothing, so maps below it are ordered lexically. Nodes are shared
+// between documents and must not be modified once built; see mutable.
+type keyOrder struct {
+	names  []string
+	fields map[string]*keyOrder
+	elems  []*keyOrder
+}
+
+// mapOrder returns the node of a map with the given keys.
+func mapOrder(names []string) *keyOrder {
+	return &keyOrder{names: names, fields: make(map[string]*keyOrder)}
+}
+
+// field returns the node of the value of key in a map.
+func (o *keyOrder) field(key string) *keyOrder {
+	if o == nil {
+		return nil
+	}
+	return o.fields[key]
+}
+
+// elem returns the node of the element at index i of a list.
+func (o *keyOrder) elem(i int) *keyOrder {
+	if o == nil || i < 0 || i >= len(o.elems) {
+		return nil
+	}
+	return o.elems[i]
+}
+
+// setField sets the node of the value of key, leaving nil nodes out.
+func (o *keyOrder) setField(key string, child *keyOrder) {
+	if child == nil {
+		delete(o.fields, key)
+		return
+	}
+	if o.fields == nil {
+		o.fields = make(map[string]*keyOrder)
+	}
+	o.fields[key] = child
+}
+
+// setElem sets the node of the element at index i of a list.
+func (o *keyOrder) setElem(i int, child *keyOrder) {
+	if child == nil && i >= len(o.elems) {
+		return
+	}
+	for len(o.elems) <= i {
+		o.elems = append(o.elems, nil)
+	}
+	o.elems[i] = child
+}
+
+// insertElem inserts the node of an element inserted at index i of a list.
+func (o *keyOrder) insertElem(i int, child *keyOrder) {
+	if child == nil && i >= len(o.elems) {
+		return
+	}
+	for len(o.elems) < i {
+		o.elems = append(o.elems, nil)
+	}
+	o.elems = append(o.elems, nil)
+	copy(o.elems[i+1:], o.elems[i:])
+	o.elems[i] = child
+}
+
+// removeElem removes the node of the element at index i of a list.
+func (o *keyOrder) removeElem(i int) {
+	if i < len(o.elems) {
+		o.elems = append(o.elems[:i], o.elems[i+1:]...)
+	}
+}
+
+// addName appends a key added to a map to its order.
+func (o *keyOrder) addName(key string) {
+	for _, name := range o.names {
+		if name == key {
+			return
+		}
+	}
+	o.names = append(o.names, key)
+}
+
+// removeName removes a key removed from a map, along with its node.
+func (o *keyOrder) removeName(key string) {
+	for i, name := range o.names {
+		if name == key {
+			o.names = append(o.names[:i], o.names[i+1:]...)
+			break
+		}
+	}
+	o.setField(key, nil)
+}
+
+// mutable returns a copy of the node that can be modified without affecting
+// the node itself or any tree sharing it. The nodes below are shared.
+func (o *keyOrder) mutable() *keyOrder {
+	if o == nil {
+		return &keyOrder{}
+	}
+	c := &keyOrder{
+		names: append([]string(nil), o.names...),
+		elems: append([]*keyOrder(nil), o.elems...),
+	}
+	for key, child := range o.fields {
+		c.setField(key, child)
+	}
+	return c
+}
+
+// keys returns the keys of m in source order when known. Keys missing from
+// the recorded order are appended in lexical order.
+func (o *keyOrder) keys(m map[string]interface{}) []string {
+	var recorded []string
+	if o != nil {
+		recorded = o.names
+	}
+	keys := make([]string, 0, len(m))
+	seen := make(map[string]bool, len(m))
+	for _, key := range recorded {
+		if _, exists := m[key]; exists && !seen[key] {
+			keys = append(keys, key)
+			seen[key] = true
+		}
+	}
+
+	var rest []string
+	for key := range m {
+		if !seen[key] {
+			rest = append(rest, key)
+		}
+	}
+	sort.Strings(rest)
+	return append(keys, rest...)
+}
+
+// mapKeys returns the union of the keys of a and b in reporting order. In
+// document order, keys only present in b follow the key preceding them in b.
+func (c *comparer) mapKeys(a, b map[string]interface{}, orderA, orderB *keyOrder) []string {
+	if c.opts.Order == SortPath || orderA == nil && orderB == nil {
+		keys := make([]string, 0, len(a)+len(b))
+		for key := range a {
+			keys = append(keys, key)
+		}
+		for key := range b {
+			if _, exists := a[key]; !exists {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		return keys
+	}
+
+	keys := orderA.keys(a)
+	position := make(map[string]int, len(keys))
+	for i, key := range keys {
+		position[key] = i
+	}
+
+	after := -1
+	for _, key := range orderB.keys(b) {
+		if pos, exists := position[key]; exists {
+			after = pos
+			continue
+		}
+		keys = append(keys, "")
+		copy(keys[after+2:], keys[after+1:])
+		keys[after+1] = key
+		after++
+		for i := after; i < len(keys); i++ {
+			position[keys[i]] = i
+		}
+	}
+	return keys
+}
+
+// sortDiffs orders differences lexically by path, comparing list indexes
+// numerically.
+func sortDiffs(diffs []Diff) {
+	sort.SliceStable(diffs, func(i, j int) bool {
+		return comparePaths(diffs[i].Path, diffs[j].Path) < 0
+	})
+}
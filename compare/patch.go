@@ -0,0 +1,458 @@
+package compare
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PatchOperation is a single RFC 6902 JSON Patch operation.
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+
+	// order records the key order of Value.
+	order *keyOrder
+}
+
+// MarshalJSON always emits the value of add, replace and test operations,
+// since null is a valid value for them.
+func (op PatchOperation) MarshalJSON() ([]byte, error) {
+	type operation PatchOperation
+	switch op.Op {
+	case "add", "replace", "test":
+		return json.Marshal(struct {
+			operation
+			Value interface{} `json:"value"`
+		}{operation(op), op.Value})
+	}
+	return json.Marshal(operation(op))
+}
+
+// JSONPatch converts the result of a comparison into an RFC 6902 patch that
+// turns the first document into the second. Operations are ordered so that
+// every path is valid at the time it is applied: changes inside a container
+// come before changes that shift the container's own elements.
+//
+// The patch is only complete when the comparison was: filtered comparisons
+// produce partial patches, and lists compared as unordered are patched as
+// multisets, so their element order may differ from the second document.
+func JSONPatch(diffs []Diff) []PatchOperation {
+	type container struct {
+		parent Path
+		list   bool
+		diffs  []Diff
+	}
+	var containers []*container
+	byPointer := make(map[string]*container)
+	var ops []PatchOperation
+
+	for _, d := range diffs {
+		if len(d.Path) == 0 {
+			ops = append(ops, PatchOperation{Op: "replace", Path: "", Value: d.NewValue, order: d.order})
+			continue
+		}
+		parent := d.Path[:len(d.Path)-1]
+		list := d.Path[len(d.Path)-1].Element
+		key := parent.Pointer() + "\x00" + strconv.FormatBool(list)
+		c, exists := byPointer[key]
+		if !exists {
+			c = &container{parent: parent, list: list}
+			byPointer[key] = c
+			containers = append(containers, c)
+		}
+		c.diffs = append(c.diffs, d)
+	}
+
+	sort.SliceStable(containers, func(i, j int) bool {
+		return len(containers[i].parent) > len(containers[j].parent)
+	})
+	for _, c := range containers {
+		if c.list {
+			ops = append(ops, listPatch(c.parent, c.diffs)...)
+		} else {
+			ops = append(ops, mapPatch(c.diffs)...)
+		}
+	}
+	return ops
+}
+
+func mapPatch(diffs []Diff) []PatchOperation {
+	var ops []PatchOperation
+	for _, d := range diffs {
+		switch d.Type {
+		case DiffAdded:
+			ops = append(ops, PatchOperation{Op: "add", Path: d.Path.Pointer(), Value: d.NewValue, order: d.order})
+		case DiffRemoved:
+			ops = append(ops, PatchOperation{Op: "remove", Path: d.Path.Pointer()})
+		case DiffModified:
+			ops = append(ops, PatchOperation{Op: "replace", Path: d.Path.Pointer(), Value: d.NewValue, order: d.order})
+		}
+	}
+	return ops
+}
+
+// listPatch orders the changes to a single list. Removed, modified and moved
+// elements are addressed by their index in the first list, added elements and
+// move targets by their index in the second. The list is simulated while the
+// operations are generated so that each one uses the index current at the
+// time it is applied.
+func listPatch(parent Path, diffs []Diff) []PatchOperation {
+	type slot struct {
+		orig    int // index in the first list, -1 for placed elements
+		pending bool
+	}
+	var ops []PatchOperation
+	var removals []int
+	var placements []Diff
+	pendingMoves := make(map[int]bool)
+	maxIndex := -1
+
+	for _, d := range diffs {
+		index := d.Path[len(d.Path)-1].Index
+		switch d.Type {
+		case DiffModified:
+			ops = append(ops, PatchOperation{Op: "replace", Path: d.Path.Pointer(), Value: d.NewValue, order: d.order})
+		case DiffRemoved:
+			removals = append(removals, index)
+			maxIndex = max(maxIndex, index)
+		case DiffAdded:
+			placements = append(placements, d)
+		case DiffMoved:
+			from := d.From[len(d.From)-1].Index
+			pendingMoves[from] = true
+			maxIndex = max(maxIndex, from)
+			placements = append(placements, d)
+		}
+	}
+
+	slots := make([]slot, maxIndex+1)
+	for i := range slots {
+		slots[i] = slot{orig: i, pending: pendingMoves[i]}
+	}
+	find := func(orig int) int {
+		for pos, s := range slots {
+			if s.orig == orig {
+				return pos
+			}
+		}
+		return -1
+	}
+	elem := func(pos int) string {
+		return parent.Elem(pos).Pointer()
+	}
+
+	sort.Sort(sort.Reverse(sort.IntSlice(removals)))
+	for _, index := range removals {
+		pos := find(index)
+		ops = append(ops, PatchOperation{Op: "remove", Path: elem(pos)})
+		slots = append(slots[:pos], slots[pos+1:]...)
+	}
+
+	sort.SliceStable(placements, func(i, j int) bool {
+		return placements[i].Path[len(placements[i].Path)-1].Index <
+			placements[j].Path[len(placements[j].Path)-1].Index
+	})
+	for _, d := range placements {
+		var from int
+		if d.Type == DiffMoved {
+			from = find(d.From[len(d.From)-1].Index)
+			slots = append(slots[:from], slots[from+1:]...)
+		}
+
+		// Insert where exactly target settled elements precede the new one;
+		// elements still waiting to be moved do not count. Elements beyond
+		// the last index mentioned by a diff are untouched and settled.
+		target := d.Path[len(d.Path)-1].Index
+		pos, settled := 0, 0
+		for settled < target {
+			if pos == len(slots) {
+				slots = append(slots, slot{orig: maxIndex + 1})
+				maxIndex++
+			}
+			if !slots[pos].pending {
+				settled++
+			}
+			pos++
+		}
+		slots = append(slots, slot{})
+		copy(slots[pos+1:], slots[pos:])
+		slots[pos] = slot{orig: -1}
+
+		if d.Type == DiffMoved {
+			ops = append(ops, PatchOperation{Op: "move", From: elem(from), Path: elem(pos)})
+		} else {
+			ops = append(ops, PatchOperation{Op: "add", Path: elem(pos), Value: d.NewValue, order: d.order})
+		}
+	}
+	return ops
+}
+
+// ApplyPatch applies an RFC 6902 patch to doc and returns the result. The
+// document is modified in place where possible.
+func ApplyPatch(doc interface{}, ops []PatchOperation) (interface{}, error) {
+	doc, _, err := applyPatch(doc, nil, ops)
+	return doc, err
+}
+
+// applyPatch applies a patch to doc and to its key order, returning both. The
+// order is copied where it changes, so nodes shared with other trees are left
+// untouched.
+func applyPatch(doc interface{}, order *keyOrder, ops []PatchOperation) (interface{}, *keyOrder, error) {
+	var err error
+	for i, op := range ops {
+		doc, order, err = applyOperation(doc, order, op)
+		if err != nil {
+			return nil, nil, fmt.Errorf("patch operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return doc, order, nil
+}
+
+func applyOperation(doc interface{}, order *keyOrder, op PatchOperation) (interface{}, *keyOrder, error) {
+	switch op.Op {
+	case "add":
+		return patchAt(doc, order, op.Path, func(parent interface{}, parentOrder *keyOrder, key string) (interface{}, *keyOrder, error) {
+			return addValue(parent, parentOrder, key, op.Value, op.order)
+		}, op.Value, op.order)
+	case "remove":
+		doc, order, _, _, err := removeAt(doc, order, op.Path)
+		return doc, order, err
+	case "replace":
+		if _, _, err := valueAt(doc, order, op.Path); err != nil {
+			return nil, nil, err
+		}
+		return patchAt(doc, order, op.Path, func(parent interface{}, parentOrder *keyOrder, key string) (interface{}, *keyOrder, error) {
+			return replaceValue(parent, parentOrder, key, op.Value, op.order)
+		}, op.Value, op.order)
+	case "move":
+		if op.From != op.Path && strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, nil, errors.New("cannot move a value into itself")
+		}
+		doc, order, value, valueOrder, err := removeAt(doc, order, op.From)
+		if err != nil {
+			return nil, nil, err
+		}
+		return applyOperation(doc, order, PatchOperation{Op: "add", Path: op.Path, Value: value, order: valueOrder})
+	case "copy":
+		value, valueOrder, err := valueAt(doc, order, op.From)
+		if err != nil {
+			return nil, nil, err
+		}
+		return applyOperation(doc, order, PatchOperation{Op: "add", Path: op.Path, Value: copyValue(value), order: valueOrder})
+	case "test":
+		value, _, err := valueAt(doc, order, op.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		c, _ := newComparer(Options{})
+		if !c.equal(value, op.Value) {
+			return nil, nil, errors.New("test failed")
+		}
+		return doc, order, nil
+	}
+	return nil, nil, fmt.Errorf("unsupported operation %q", op.Op)
+}
+
+// patchFunc updates the container holding the value at a pointer, given its
+// key order and the last pointer token, and returns both.
+type patchFunc func(parent interface{}, parentOrder *keyOrder, key string) (interface{}, *keyOrder, error)
+
+// patchAt calls fn with the container holding the value at pointer and the
+// last pointer token, storing the container it returns back into the
+// document. A root pointer replaces the whole document with root.
+func patchAt(doc interface{}, order *keyOrder, pointer string, fn patchFunc, root interface{}, rootOrder *keyOrder) (interface{}, *keyOrder, error) {
+	path, err := ParsePointer(pointer)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(path) == 0 {
+		return root, rootOrder, nil
+	}
+	return patchPath(doc, order, path, fn)
+}
+
+func patchPath(doc interface{}, order *keyOrder, path Path, fn patchFunc) (interface{}, *keyOrder, error) {
+	key := path[0].Key
+	if len(path) == 1 {
+		return fn(doc, order, key)
+	}
+
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		child, exists := v[key]
+		if !exists {
+			return nil, nil, fmt.Errorf("key %q not found", key)
+		}
+		updated, updatedOrder, err := patchPath(child, order.field(key), path[1:], fn)
+		if err != nil {
+			return nil, nil, err
+		}
+		v[key] = updated
+		order = order.mutable()
+		order.setField(key, updatedOrder)
+		return v, order, nil
+	case []interface{}:
+		index, err := listIndex(key, len(v))
+		if err != nil {
+			return nil, nil, err
+		}
+		updated, updatedOrder, err := patchPath(v[index], order.elem(index), path[1:], fn)
+		if err != nil {
+			return nil, nil, err
+		}
+		v[index] = updated
+		order = order.mutable()
+		order.setElem(index, updatedOrder)
+		return v, order, nil
+	}
+	return nil, nil, fmt.Errorf("cannot traverse %T with %q", doc, key)
+}
+
+func addValue(parent interface{}, order *keyOrder, key string, value interface{}, valueOrder *keyOrder) (interface{}, *keyOrder, error) {
+	switch v := parent.(type) {
+	case map[string]interface{}:
+		order = order.mutable()
+		if _, exists := v[key]; !exists {
+			order.addName(key)
+		}
+		v[key] = value
+		order.setField(key, valueOrder)
+		return v, order, nil
+	case []interface{}:
+		index := len(v)
+		if key != "-" {
+			var err error
+			if index, err = listIndex(key, len(v)+1); err != nil {
+				return nil, nil, err
+			}
+		}
+		v = append(v, nil)
+		copy(v[index+1:], v[index:])
+		v[index] = value
+		order = order.mutable()
+		order.insertElem(index, valueOrder)
+		return v, order, nil
+	}
+	return nil, nil, fmt.Errorf("cannot add %q to %T", key, parent)
+}
+
+func replaceValue(parent interface{}, order *keyOrder, key string, value interface{}, valueOrder *keyOrder) (interface{}, *keyOrder, error) {
+	switch v := parent.(type) {
+	case map[string]interface{}:
+		v[key] = value
+		order = order.mutable()
+		order.setField(key, valueOrder)
+		return v, order, nil
+	case []interface{}:
+		index, err := listIndex(key, len(v))
+		if err != nil {
+			return nil, nil, err
+		}
+		v[index] = value
+		order = order.mutable()
+		order.setElem(index, valueOrder)
+		return v, order, nil
+	}
+	return nil, nil, fmt.Errorf("cannot replace %q in %T", key, parent)
+}
+
+// removeAt removes the value at pointer, returning the updated document and
+// order along with the removed value and its order.
+func removeAt(doc interface{}, order *keyOrder, pointer string) (interface{}, *keyOrder, interface{}, *keyOrder, error) {
+	var removed interface{}
+	var removedOrder *keyOrder
+	path, err := ParsePointer(pointer)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if len(path) == 0 {
+		return nil, nil, doc, order, nil
+	}
+	doc, order, err = patchPath(doc, order, path, func(parent interface{}, parentOrder *keyOrder, key string) (interface{}, *keyOrder, error) {
+		switch v := parent.(type) {
+		case map[string]interface{}:
+			value, exists := v[key]
+			if !exists {
+				return nil, nil, fmt.Errorf("key %q not found", key)
+			}
+			removed, removedOrder = value, parentOrder.field(key)
+			delete(v, key)
+			parentOrder = parentOrder.mutable()
+			parentOrder.removeName(key)
+			return v, parentOrder, nil
+		case []interface{}:
+			index, err := listIndex(key, len(v))
+			if err != nil {
+				return nil, nil, err
+			}
+			removed, removedOrder = v[index], parentOrder.elem(index)
+			parentOrder = parentOrder.mutable()
+			parentOrder.removeElem(index)
+			return append(v[:index], v[index+1:]...), parentOrder, nil
+		}
+		return nil, nil, fmt.Errorf("cannot remove %q from %T", key, parent)
+	})
+	return doc, order, removed, removedOrder, err
+}
+
+func valueAt(doc interface{}, order *keyOrder, pointer string) (interface{}, *keyOrder, error) {
+	path, err := ParsePointer(pointer)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, segment := range path {
+		switch v := doc.(type) {
+		case map[string]interface{}:
+			child, exists := v[segment.Key]
+			if !exists {
+				return nil, nil, fmt.Errorf("key %q not found", segment.Key)
+			}
+			doc, order = child, order.field(segment.Key)
+		case []interface{}:
+			index, err := listIndex(segment.Key, len(v))
+			if err != nil {
+				return nil, nil, err
+			}
+			doc, order = v[index], order.elem(index)
+		default:
+			return nil, nil, fmt.Errorf("cannot traverse %T with %q", doc, segment.Key)
+		}
+	}
+	return doc, order, nil
+}
+
+// listIndex parses a pointer token as an index below limit.
+func listIndex(token string, limit int) (int, error) {
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, fmt.Errorf("invalid list index %q", token)
+	}
+	if index >= limit {
+		return 0, fmt.Errorf("list index %d out of range", index)
+	}
+	return index, nil
+}
+
+func copyValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for key, value := range t {
+			m[key] = copyValue(value)
+		}
+		return m
+	case []interface{}:
+		list := make([]interface{}, len(t))
+		for i, value := range t {
+			list[i] = copyValue(value)
+		}
+		return list
+	}
+	return v
+}
@@ -0,0 +1,126 @@
+package compare
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestJSONPatchRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		a, b  string
+		opts  Options
+		exact bool
+	}{
+		{name: "identical", a: `{"a": 1}`, b: `{"a": 1}`, exact: true},
+		{name: "scalar change", a: `{"a": 1, "b": "x"}`, b: `{"a": 2, "b": "x"}`, exact: true},
+		{name: "add and remove keys", a: `{"a": 1, "b": {"c": true}}`, b: `{"a": 1, "d": [1]}`, exact: true},
+		{name: "type change", a: `{"a": [1, 2]}`, b: `{"a": {"b": 2}}`, exact: true},
+		{name: "pointer escaping", a: `{"a/b": {"c~d": 1}}`, b: `{"a/b": {"c~d": 2}}`, exact: true},
+		{name: "list insert and delete", a: `{"l": [1, 2, 3, 4]}`, b: `{"l": [0, 1, 3, 4, 5]}`, exact: true},
+		{name: "list of maps", a: `{"l": [{"a": 1}, {"a": 2}]}`, b: `{"l": [{"a": 1}, {"a": 3}, {"a": 4}]}`, exact: true},
+		{name: "list emptied", a: `{"l": [1, 2, 3]}`, b: `{"l": []}`, exact: true},
+		{
+			name:  "keyed list changes",
+			a:     `{"items": [{"name": "a", "v": 1}, {"name": "b", "v": 2}, {"name": "c", "v": 3}]}`,
+			b:     `{"items": [{"name": "a", "v": 1}, {"name": "b", "v": 5}, {"name": "d", "v": 4}]}`,
+			opts:  Options{ListKeys: []ListKey{{Path: "items", Fields: []string{"name"}}}},
+			exact: true,
+		},
+		{
+			name:  "keyed list moves",
+			a:     `{"items": [{"name": "a"}, {"name": "b"}, {"name": "c"}, {"name": "d"}]}`,
+			b:     `{"items": [{"name": "d"}, {"name": "b"}, {"name": "a", "v": 1}, {"name": "c"}]}`,
+			opts:  Options{ListKeys: []ListKey{{Path: "items", Fields: []string{"name"}}}},
+			exact: true,
+		},
+		{
+			name: "unordered list",
+			a:    `{"l": [1, 2, 3, 2]}`,
+			b:    `{"l": [3, 2, 4, 1, 5]}`,
+			opts: Options{Unordered: true},
+		},
+		{
+			name: "unordered path",
+			a:    `{"tags": ["x", "y"], "l": [1, 2]}`,
+			b:    `{"tags": ["z", "x"], "l": [2, 1]}`,
+			opts: Options{UnorderedPaths: []string{"tags"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := mustDecodeJSON(t, tt.a), mustDecodeJSON(t, tt.b)
+			ops := JSONPatch(CompareValues(a, b, nil, tt.opts))
+			got, err := ApplyPatch(copyValue(a), ops)
+			if err != nil {
+				t.Fatalf("ApplyPatch(%v): %v", ops, err)
+			}
+			if tt.exact {
+				if !reflect.DeepEqual(got, b) {
+					t.Errorf("patch %v produced %v, want %v", ops, got, b)
+				}
+			} else if diffs := CompareValues(got, b, nil, tt.opts); len(diffs) > 0 {
+				t.Errorf("patch %v produced %v, which differs from %v: %v", ops, got, b, diffs)
+			}
+			if !reflect.DeepEqual(a, mustDecodeJSON(t, tt.a)) {
+				t.Errorf("ApplyPatch modified the original document")
+			}
+		})
+	}
+}
+
+func mustDecodeJSON(t *testing.T, s string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestApplyPatchDocumentKeepsKeyOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+		want  string
+	}{
+		{
+			name:  "json patch",
+			patch: `[{"op": "add", "path": "/l/0", "value": {"z": 1, "a": 2}}, {"op": "move", "from": "/m", "path": "/c"}]`,
+			want:  `{"z": 1, "b": {"y": 1, "x": 2}, "l": [{"z": 1, "a": 2}, {"q": 1, "p": 2}], "c": {"k": 1, "j": 2}}`,
+		},
+		{
+			name:  "merge patch",
+			patch: `{"b": {"w": 3}, "n": {"s": 1, "r": 2}, "m": null}`,
+			want:  `{"z": 1, "b": {"y": 1, "x": 2, "w": 3}, "l": [{"q": 1, "p": 2}], "n": {"s": 1, "r": 2}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, order, err := decodeJSON([]byte(`{"z": 1, "b": {"y": 1, "x": 2}, "l": [{"q": 1, "p": 2}], "m": {"k": 1, "j": 2}}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			patched, patchedOrder, err := applyPatchDocument(doc, order, []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("applyPatchDocument: %v", err)
+			}
+			got, err := encodeDocument(patched, "json", patchedOrder)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, wantOrder, err := decodeJSON([]byte(tt.want))
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantJSON, _ := encodeDocument(want, "json", wantOrder)
+			if string(got) != string(wantJSON) {
+				t.Errorf("got\n%s\nwant\n%s", got, wantJSON)
+			}
+			if names := []string{"z", "b", "l", "m"}; !reflect.DeepEqual(order.names, names) {
+				t.Errorf("the order of the document was modified: %v, want %v", order.names, names)
+			}
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package compare
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PathSegment is one step of a Path: either a map key or a list element.
+type PathSegment struct {
+	Key string
+	// Element marks list elements, addressed by Index. Elements matched by
+	// key fields also carry a Selector such as "name=web"; Index is -1 when
+	// only the selector is known, e.g. after parsing a rendered path.
+	Element  bool
+	Index    int
+	Selector string
+}
+
+// Path locates a value inside a decoded document.
+type Path []PathSegment
+
+// Child returns a copy of p extended with a map key.
+func (p Path) Child(key string) Path {
+	return p.append(PathSegment{Key: key})
+}
+
+// Elem returns a copy of p extended with a list index.
+func (p Path) Elem(index int) Path {
+	return p.append(PathSegment{Element: true, Index: index})
+}
+
+// KeyedElem returns a copy of p extended with a list element matched by its
+// key fields.
+func (p Path) KeyedElem(index int, selector string) Path {
+	return p.append(PathSegment{Element: true, Index: index, Selector: selector})
+}
+
+func (p Path) append(segment PathSegment) Path {
+	out := make(Path, len(p)+1)
+	copy(out, p)
+	out[len(p)] = segment
+	return out
+}
+
+// String renders the path for humans, e.g. `spec.containers[name=web].image`
+// or `metadata.labels["app.kubernetes.io/name"]`. Keys that are not plain
+// identifiers are quoted so that the result can be parsed back by ParsePath.
+func (p Path) String() string {
+	var sb strings.Builder
+	for i, segment := range p {
+		switch {
+		case segment.Element && segment.Selector != "":
+			sb.WriteString("[" + segment.Selector + "]")
+		case segment.Element:
+			sb.WriteString("[" + strconv.Itoa(segment.Index) + "]")
+		case isBareKey(segment.Key):
+			if i > 0 {
+				sb.WriteString(".")
+			}
+			sb.WriteString(segment.Key)
+		default:
+			sb.WriteString("[" + strconv.Quote(segment.Key) + "]")
+		}
+	}
+	return sb.String()
+}
+
+// Pointer renders the path as an RFC 6901 JSON Pointer. List elements are
+// always addressed by index.
+func (p Path) Pointer() string {
+	var sb strings.Builder
+	for _, segment := range p {
+		sb.WriteString("/")
+		if segment.Element {
+			sb.WriteString(strconv.Itoa(segment.Index))
+		} else {
+			sb.WriteString(pointerEscaper.Replace(segment.Key))
+		}
+	}
+	return sb.String()
+}
+
+var (
+	pointerEscaper   = strings.NewReplacer("~", "~0", "/", "~1")
+	pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+)
+
+func (p Path) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *Path) UnmarshalText(text []byte) error {
+	parsed, err := ParsePath(string(text))
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
+
+// isBareKey reports whether key is a plain identifier, optionally prefixed
+// with "@", "#" or "?" like the attribute, text and processing instruction
+// keys of XML documents.
+func isBareKey(key string) bool {
+	if key != "" && strings.ContainsRune("@#?", rune(key[0])) {
+		key = key[1:]
+	}
+	if key == "" {
+		return false
+	}
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// formatSelector renders the key fields of a list element, quoting values
+// that are not plain identifiers.
+func formatSelector(fields []string, values []interface{}) string {
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		value := fmt.Sprint(values[i])
+		if !isBareKey(value) {
+			value = strconv.Quote(value)
+		}
+		parts[i] = field + "=" + value
+	}
+	return strings.Join(parts, ",")
+}
+
+// ParsePath parses the human form produced by Path.String.
+func ParsePath(s string) (Path, error) {
+	tokens, err := tokenizePath(s)
+	if err != nil {
+		return nil, err
+	}
+	path := Path{}
+	for _, tok := range tokens {
+		if tok.wildcard {
+			return nil, fmt.Errorf("invalid path %q: wildcards are only allowed in patterns", s)
+		}
+		path = append(path, tok.segment)
+	}
+	return path, nil
+}
+
+// ParsePointer parses an RFC 6901 JSON Pointer. Since a pointer does not
+// distinguish list indexes from map keys, every segment is returned as a key.
+func ParsePointer(s string) (Path, error) {
+	if s == "" {
+		return Path{}, nil
+	}
+	if !strings.HasPrefix(s, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q: must start with /", s)
+	}
+	path := Path{}
+	for _, part := range strings.Split(s[1:], "/") {
+		path = append(path, PathSegment{Key: pointerUnescaper.Replace(part)})
+	}
+	return path, nil
+}
+
+type pathToken struct {
+	segment  PathSegment
+	wildcard bool // "*", "[*]" or "**" in a pattern
+}
+
+func tokenizePath(s string) ([]pathToken, error) {
+	var tokens []pathToken
+	for i := 0; i < len(s); {
+		switch {
+		case s[i] == '.':
+			if i == 0 || i == len(s)-1 || s[i+1] == '.' || s[i+1] == '[' {
+				return nil, fmt.Errorf("invalid path %q: unexpected '.' at %d", s, i)
+			}
+			i++
+		case s[i] == '[':
+			tok, n, err := tokenizeBracket(s[i:])
+			if err != nil {
+				return nil, fmt.Errorf("invalid path %q: %v", s, err)
+			}
+			tokens = append(tokens, tok)
+			i += n
+		default:
+			end := i
+			for end < len(s) && s[end] != '.' && s[end] != '[' {
+				end++
+			}
+			key := s[i:end]
+			tokens = append(tokens, pathToken{
+				segment:  PathSegment{Key: key},
+				wildcard: key == "*" || key == "**",
+			})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+// tokenizeBracket parses a leading "[...]" segment and returns its length.
+func tokenizeBracket(s string) (pathToken, int, error) {
+	if strings.HasPrefix(s, `["`) {
+		quoted, err := strconv.QuotedPrefix(s[1:])
+		if err != nil || !strings.HasPrefix(s[1+len(quoted):], "]") {
+			return pathToken{}, 0, fmt.Errorf("unterminated quoted key")
+		}
+		key, _ := strconv.Unquote(quoted)
+		return pathToken{segment: PathSegment{Key: key}}, len(quoted) + 2, nil
+	}
+
+	// Find the closing bracket, skipping over quoted selector values.
+	end := 1
+	for end < len(s) && s[end] != ']' {
+		if s[end] == '"' {
+			quoted, err := strconv.QuotedPrefix(s[end:])
+			if err != nil {
+				return pathToken{}, 0, fmt.Errorf("unterminated quoted selector value")
+			}
+			end += len(quoted)
+			continue
+		}
+		end++
+	}
+	if end >= len(s) {
+		return pathToken{}, 0, fmt.Errorf("missing ']'")
+	}
+
+	inner := s[1:end]
+	switch {
+	case inner == "*":
+		return pathToken{segment: PathSegment{Element: true, Index: -1}, wildcard: true}, end + 1, nil
+	case inner == "":
+		return pathToken{}, 0, fmt.Errorf("empty brackets")
+	}
+	if index, err := strconv.Atoi(inner); err == nil && index >= 0 {
+		return pathToken{segment: PathSegment{Element: true, Index: index}}, end + 1, nil
+	}
+	return pathToken{segment: PathSegment{Element: true, Index: -1, Selector: inner}}, end + 1, nil
+}
+
+// comparePaths orders paths segment by segment, comparing list indexes
+// numerically and placing map keys before list elements.
+func comparePaths(a, b Path) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := compareSegments(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return len(a) - len(b)
+}
+
+func compareSegments(a, b PathSegment) int {
+	switch {
+	case a.Element && b.Element:
+		if a.Selector != "" || b.Selector != "" {
+			if c := strings.Compare(a.Selector, b.Selector); c != 0 {
+				return c
+			}
+		}
+		return a.Index - b.Index
+	case a.Element:
+		return 1
+	case b.Element:
+		return -1
+	}
+	return strings.Compare(a.Key, b.Key)
+}
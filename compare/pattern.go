@@ -0,0 +1,70 @@
+package compare
+
+// pathPattern is a compiled path expression such as "spec.containers[*]". A
+// "*" segment matches any map key, "[*]" matches any list element and "**"
+// matches any number of segments. Other segments match literally.
+type pathPattern []pathToken
+
+func compilePattern(pattern string) (pathPattern, error) {
+	tokens, err := tokenizePath(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return pathPattern(tokens), nil
+}
+
+func (t pathToken) isGlobstar() bool {
+	return t.wildcard && !t.segment.Element && t.segment.Key == "**"
+}
+
+func (p pathPattern) match(path Path) bool {
+	if len(p) == 0 {
+		return len(path) == 0
+	}
+	if p[0].isGlobstar() {
+		for i := 0; i <= len(path); i++ {
+			if p[1:].match(path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 || !p[0].matchSegment(path[0]) {
+		return false
+	}
+	return p[1:].match(path[1:])
+}
+
+// matchPrefix reports whether path can be extended into a path matching the
+// pattern.
+func (p pathPattern) matchPrefix(path Path) bool {
+	if len(path) == 0 {
+		return true
+	}
+	if len(p) == 0 {
+		return false
+	}
+	if p[0].isGlobstar() {
+		return true
+	}
+	if !p[0].matchSegment(path[0]) {
+		return false
+	}
+	return p[1:].matchPrefix(path[1:])
+}
+
+func (t pathToken) matchSegment(segment PathSegment) bool {
+	if t.segment.Element != segment.Element {
+		return false
+	}
+	switch {
+	case t.wildcard:
+		return true
+	case !segment.Element:
+		return t.segment.Key == segment.Key
+	case t.segment.Selector != "":
+		return t.segment.Selector == segment.Selector
+	default:
+		return t.segment.Index == segment.Index
+	}
+}
@@ -0,0 +1,104 @@
+package compare
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UnifiedDiff returns a line-based diff of two texts in unified format with
+// three lines of context, or an empty string if they are equal. It is used
+// for files whose format is not recognized.
+func UnifiedDiff(a, b []byte, aName, bName string) string {
+	const context = 3
+
+	aLines := splitLines(string(a))
+	bLines := splitLines(string(b))
+	edits := myersDiff(len(aLines), len(bLines), func(i, j int) bool {
+		return aLines[i] == bLines[j]
+	})
+
+	// Line positions in a and b before each edit.
+	aPos := make([]int, len(edits)+1)
+	bPos := make([]int, len(edits)+1)
+	for i, e := range edits {
+		aPos[i+1], bPos[i+1] = aPos[i], bPos[i]
+		if e.Kind != editInsert {
+			aPos[i+1]++
+		}
+		if e.Kind != editDelete {
+			bPos[i+1]++
+		}
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(edits); {
+		if edits[i].Kind == editEqual {
+			i++
+			continue
+		}
+
+		// Extend the hunk while the next change is close enough for their
+		// contexts to touch.
+		start := max(i-context, 0)
+		end := i
+		for end < len(edits) {
+			next := end
+			for next < len(edits) && edits[next].Kind == editEqual {
+				next++
+			}
+			if next == len(edits) || next-end > 2*context {
+				break
+			}
+			for next < len(edits) && edits[next].Kind != editEqual {
+				next++
+			}
+			end = next
+		}
+		end = min(end+context, len(edits))
+
+		if sb.Len() == 0 {
+			sb.WriteString("--- " + aName + "\n")
+			sb.WriteString("+++ " + bName + "\n")
+		}
+		sb.WriteString(fmt.Sprintf("@@ -%s +%s @@\n",
+			hunkRange(aPos[start], aPos[end]-aPos[start]),
+			hunkRange(bPos[start], bPos[end]-bPos[start])))
+		for _, e := range edits[start:end] {
+			switch e.Kind {
+			case editEqual:
+				writeDiffLine(&sb, " ", aLines[e.AIndex])
+			case editDelete:
+				writeDiffLine(&sb, "-", aLines[e.AIndex])
+			case editInsert:
+				writeDiffLine(&sb, "+", bLines[e.BIndex])
+			}
+		}
+		i = end
+	}
+	return sb.String()
+}
+
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func hunkRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+func writeDiffLine(sb *strings.Builder, prefix, line string) {
+	sb.WriteString(prefix + line)
+	if !strings.HasSuffix(line, "\n") {
+		sb.WriteString("\n\\ No newline at end of file\n")
+	}
+}
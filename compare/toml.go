@@ -1,9 +1,10 @@
 package compare
 
 import (
-	"fmt"
+	"strings"
 
 	"github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
 )
 
 type TOMLValidator struct{}
@@ -25,28 +26,113 @@ type TOMLComparator struct {
 	TOMLValidator
 }
 
-func (t *TOMLComparator) Compare(file1, file2 string, ignoreCase bool, config RemoteConfig) ([]Diff, error) {
-	data1, err := readFileContent(file1, config)
-	if err != nil {
-		return nil, fmt.Errorf("error reading first file: %w", err)
+func (t *TOMLComparator) Compare(doc1, doc2 *Document, opts Options) ([]Diff, error) {
+	return compareTrees(doc1, doc2, opts), nil
+}
+
+func (t *TOMLComparator) Validator() FileValidator {
+	return &t.TOMLValidator
+}
+
+func (t *TOMLComparator) parse(data []byte, name string) (interface{}, *keyOrder, error) {
+	return decodeTOML(data)
+}
+
+// decodeTOML decodes a TOML document into an interface{}, along with the key
+// order of every table taken from the document's syntax tree.
+func decodeTOML(data []byte) (interface{}, *keyOrder, error) {
+	var value interface{}
+	if err := toml.Unmarshal(data, &value); err != nil {
+		return nil, nil, err
 	}
 
-	data2, err := readFileContent(file2, config)
+	tables, err := tomlKeyOrder(data)
 	if err != nil {
-		return nil, fmt.Errorf("error reading second file: %w", err)
+		return nil, nil, err
 	}
+	return value, tomlOrder(value, "", tables), nil
+}
 
-	var obj1, obj2 interface{}
-	if err := toml.Unmarshal(data1, &obj1); err != nil {
-		return nil, fmt.Errorf("error parsing first TOML file: %w", err)
+// tomlKeyOrder collects the keys of every table in source order. Tables are
+// identified by their dotted path; the elements of an array of tables share
+// the path of the array.
+func tomlKeyOrder(data []byte) (map[string][]string, error) {
+	tables := make(map[string][]string)
+	seen := make(map[string]bool)
+	add := func(table []string, key string) {
+		path := strings.Join(table, "\x00")
+		if id := path + "\x01" + key; !seen[id] {
+			seen[id] = true
+			tables[path] = append(tables[path], key)
+		}
 	}
-	if err := toml.Unmarshal(data2, &obj2); err != nil {
-		return nil, fmt.Errorf("error parsing second TOML file: %w", err)
+
+	var addKeyValue func(table []string, kv *unstable.Node)
+	var addValue func(path []string, value *unstable.Node)
+	addKeyValue = func(table []string, kv *unstable.Node) {
+		key := tomlKey(kv.Key())
+		for i := range key {
+			add(append(table[:len(table):len(table)], key[:i]...), key[i])
+		}
+		addValue(append(table[:len(table):len(table)], key...), kv.Value())
+	}
+	addValue = func(path []string, value *unstable.Node) {
+		switch value.Kind {
+		case unstable.InlineTable:
+			for it := value.Children(); it.Next(); {
+				addKeyValue(path, it.Node())
+			}
+		case unstable.Array:
+			for it := value.Children(); it.Next(); {
+				addValue(path, it.Node())
+			}
+		}
 	}
 
-	return CompareValues(obj1, obj2, "", ignoreCase), nil
+	p := &unstable.Parser{}
+	p.Reset(data)
+	var current []string
+	for p.NextExpression() {
+		expr := p.Expression()
+		switch expr.Kind {
+		case unstable.Table, unstable.ArrayTable:
+			current = tomlKey(expr.Key())
+			for i := range current {
+				add(current[:i], current[i])
+			}
+		case unstable.KeyValue:
+			addKeyValue(current, expr)
+		}
+	}
+	return tables, p.Error()
 }
 
-func (t *TOMLComparator) Validator() FileValidator {
-	return &t.TOMLValidator
+func tomlKey(it unstable.Iterator) []string {
+	var key []string
+	for it.Next() {
+		key = append(key, string(it.Node().Data))
+	}
+	return key
+}
+
+func tomlOrder(value interface{}, path string, tables map[string][]string) *keyOrder {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		order := mapOrder(tables[path])
+		for key, child := range v {
+			childPath := key
+			if path != "" {
+				childPath = path + "\x00" + key
+			}
+			order.setField(key, tomlOrder(child, childPath, tables))
+		}
+		return order
+	case []interface{}:
+		order := &keyOrder{}
+		for _, child := range v {
+			order.elems = append(order.elems, tomlOrder(child, path, tables))
+		}
+		return order
+	}
+	return nil
 }
@@ -0,0 +1,56 @@
+package compare
+
+func (c *comparer) isUnordered(path Path) bool {
+	if c.opts.Unordered {
+		return true
+	}
+	for _, pattern := range c.unordered {
+		if pattern.match(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// multisetDifference returns the indexes of the elements of a without an equal
+// counterpart in b, and those of b without one in a. Each element of b is
+// matched at most once, so duplicates are counted.
+func (c *comparer) multisetDifference(a, b []interface{}) ([]int, []int) {
+	matched := make([]bool, len(b))
+	var removed []int
+	for i := range a {
+		found := false
+		for j := range b {
+			if !matched[j] && c.equal(a[i], b[j]) {
+				matched[j] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			removed = append(removed, i)
+		}
+	}
+
+	var added []int
+	for j := range b {
+		if !matched[j] {
+			added = append(added, j)
+		}
+	}
+	return removed, added
+}
+
+// unorderedSlices treats both slices as multisets and reports only the
+// elements present on one side.
+func (c *comparer) unorderedSlices(a, b []interface{}, path Path, orderA, orderB *keyOrder) []Diff {
+	var diffs []Diff
+	removed, added := c.multisetDifference(a, b)
+	for _, i := range removed {
+		diffs = append(diffs, c.removed(path.Elem(i), a[i], orderA.elem(i))...)
+	}
+	for _, j := range added {
+		diffs = append(diffs, c.added(path.Elem(j), b[j], orderB.elem(j))...)
+	}
+	return diffs
+}
@@ -1,8 +1,12 @@
 package compare
 
 import (
+	"bytes"
 	"encoding/xml"
+	"errors"
 	"fmt"
+	"io"
+	"strings"
 )
 
 type XMLValidator struct{}
@@ -20,32 +24,317 @@ func (x *XMLValidator) ValidationHelp() string {
 • Special characters must be escaped`
 }
 
+// XMLOptions controls how XML documents are converted into trees.
+type XMLOptions struct {
+	// IgnoreNamespaces compares element and attribute names by their local
+	// part, whatever namespace they are in.
+	IgnoreNamespaces bool
+	// IgnoreComments and IgnoreProcessingInstructions drop comments and
+	// processing instructions, which are otherwise kept under "#comment"
+	// and "?target" keys.
+	IgnoreComments               bool
+	IgnoreProcessingInstructions bool
+	// IgnoreWhitespace trims text and collapses runs of whitespace in it.
+	IgnoreWhitespace bool
+}
+
 type XMLComparator struct {
 	XMLValidator
+	Options XMLOptions
+}
+
+// Compare compares two XML documents after aligning their repeated elements
+// (see alignXMLLists). The differences are relative to the aligned first
+// document returned by PatchBase.
+func (x *XMLComparator) Compare(doc1, doc2 *Document, opts Options) ([]Diff, error) {
+	aligned := alignXMLLists(doc1, doc2)
+	return compareOrdered(aligned.tree1, aligned.tree2, aligned.order1, aligned.order2, opts), nil
 }
 
-func (x *XMLComparator) Compare(file1, file2 string, ignoreCase bool, config RemoteConfig) ([]Diff, error) {
-	data1, err := readFileContent(file1, config)
-	if err != nil {
-		return nil, fmt.Errorf("error reading first file: %w", err)
+func (x *XMLComparator) patchBase(doc1, doc2 *Document) (interface{}, []PatchOperation) {
+	aligned := alignXMLLists(doc1, doc2)
+	var ops []PatchOperation
+	for _, path := range aligned.wrapped {
+		value, _ := lookupPath(aligned.tree1, path)
+		ops = append(ops, PatchOperation{Op: "replace", Path: path.Pointer(), Value: value})
 	}
+	return aligned.tree1, ops
+}
+
+func (x *XMLComparator) Validator() FileValidator {
+	return &x.XMLValidator
+}
+
+func (x *XMLComparator) parse(data []byte, name string) (interface{}, *keyOrder, error) {
+	return decodeXML(data, x.Options)
+}
+
+// xmlNamespace is the namespace bound to the xml prefix.
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
 
-	data2, err := readFileContent(file2, config)
-	if err != nil {
-		return nil, fmt.Errorf("error reading second file: %w", err)
+// decodeXML converts an XML document into a tree: a map holding the root
+// element under its name. An element with neither attributes nor child
+// nodes becomes its text. Any other element becomes a map of its attributes,
+// prefixed with "@", and its child elements by name, with repeated siblings
+// collected into a list. Text next to child elements is kept under "#text",
+// as a list of strings for mixed content with several runs of text;
+// whitespace between elements is dropped. Comments and processing
+// instructions outside the root element are kept next to it.
+//
+// Names are resolved to their namespace rather than compared by prefix.
+// Names in no namespace or in the namespace of the root element are written
+// bare, and the namespace of the root element is kept under "#namespace";
+// names in other namespaces are written as "{uri}name". Namespace
+// declarations themselves are dropped.
+func decodeXML(data []byte, opts XMLOptions) (interface{}, *keyOrder, error) {
+	d := &xmlDecoder{dec: xml.NewDecoder(bytes.NewReader(data)), opts: opts}
+	doc := newXMLElement()
+	rootSeen := false
+	for {
+		tok, err := d.dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if rootSeen {
+				return nil, nil, fmt.Errorf("unexpected element <%s> after the root element", t.Name.Local)
+			}
+			rootSeen = true
+			if !opts.IgnoreNamespaces {
+				d.namespace = t.Name.Space
+			}
+			value, order, err := d.element(t)
+			if err != nil {
+				return nil, nil, err
+			}
+			doc.add(d.name(t.Name), value, order)
+		case xml.CharData:
+			if len(bytes.TrimSpace(t)) > 0 {
+				return nil, nil, errors.New("unexpected text outside the root element")
+			}
+		default:
+			d.node(doc, tok)
+		}
 	}
+	if !rootSeen {
+		return nil, nil, errors.New("missing root element")
+	}
+	if d.namespace != "" {
+		doc.add("#namespace", d.namespace, nil)
+	}
+	return doc.values, doc.order, nil
+}
 
-	var obj1, obj2 interface{}
-	if err := xml.Unmarshal(data1, &obj1); err != nil {
-		return nil, fmt.Errorf("error parsing first XML file: %w", err)
+type xmlDecoder struct {
+	dec       *xml.Decoder
+	opts      XMLOptions
+	namespace string
+}
+
+// xmlElement collects the members of an element's map in document order.
+type xmlElement struct {
+	values map[string]interface{}
+	order  *keyOrder
+}
+
+func newXMLElement() *xmlElement {
+	return &xmlElement{values: make(map[string]interface{}), order: mapOrder(nil)}
+}
+
+// add sets a member along with its key order, collecting repeated members
+// into a list.
+func (e *xmlElement) add(key string, value interface{}, order *keyOrder) {
+	existing, exists := e.values[key]
+	switch {
+	case !exists:
+		e.values[key] = value
+		e.order.names = append(e.order.names, key)
+		e.order.setField(key, order)
+	case isList(existing):
+		e.values[key] = append(existing.([]interface{}), value)
+		e.order.fields[key].elems = append(e.order.fields[key].elems, order)
+	default:
+		e.values[key] = []interface{}{existing, value}
+		e.order.setField(key, &keyOrder{elems: []*keyOrder{e.order.field(key), order}})
 	}
-	if err := xml.Unmarshal(data2, &obj2); err != nil {
-		return nil, fmt.Errorf("error parsing second XML file: %w", err)
+}
+
+func (d *xmlDecoder) element(start xml.StartElement) (interface{}, *keyOrder, error) {
+	element := newXMLElement()
+	for _, attr := range start.Attr {
+		if isNamespaceDeclaration(attr.Name) {
+			continue
+		}
+		element.add("@"+d.name(attr.Name), attr.Value, nil)
 	}
 
-	return CompareValues(obj1, obj2, "", ignoreCase), nil
+	var texts []string
+	var text strings.Builder
+	nodes := false
+	flush := func() {
+		if text.Len() > 0 {
+			texts = append(texts, d.text(text.String()))
+			text.Reset()
+		}
+	}
+	for {
+		tok, err := d.dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			flush()
+			child, order, err := d.element(t)
+			if err != nil {
+				return nil, nil, err
+			}
+			element.add(d.name(t.Name), child, order)
+			nodes = true
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			flush()
+			if !nodes && len(element.values) == 0 {
+				return strings.Join(texts, ""), nil, nil
+			}
+			for _, s := range texts {
+				// Text between child nodes is only kept when it is more
+				// than indentation.
+				if nodes && strings.TrimSpace(s) == "" || s == "" {
+					continue
+				}
+				element.add("#text", s, nil)
+			}
+			return element.values, element.order, nil
+		default:
+			if d.node(element, tok) {
+				flush()
+				nodes = true
+			}
+		}
+	}
 }
 
-func (x *XMLComparator) Validator() FileValidator {
-	return &x.XMLValidator
+// node adds a comment or processing instruction to an element unless it is
+// ignored, and reports whether it was added.
+func (d *xmlDecoder) node(element *xmlElement, tok xml.Token) bool {
+	switch t := tok.(type) {
+	case xml.Comment:
+		if !d.opts.IgnoreComments {
+			element.add("#comment", d.text(string(t)), nil)
+			return true
+		}
+	case xml.ProcInst:
+		// The XML declaration is not a processing instruction.
+		if t.Target != "xml" && !d.opts.IgnoreProcessingInstructions {
+			element.add("?"+t.Target, d.text(string(t.Inst)), nil)
+			return true
+		}
+	}
+	return false
+}
+
+// name returns the key of an element or attribute name.
+func (d *xmlDecoder) name(name xml.Name) string {
+	switch {
+	case d.opts.IgnoreNamespaces || name.Space == "" || name.Space == d.namespace:
+		return name.Local
+	case name.Space == xmlNamespace:
+		return "xml:" + name.Local
+	}
+	return "{" + name.Space + "}" + name.Local
+}
+
+func (d *xmlDecoder) text(s string) string {
+	if d.opts.IgnoreWhitespace {
+		return strings.Join(strings.Fields(s), " ")
+	}
+	return s
+}
+
+func isNamespaceDeclaration(name xml.Name) bool {
+	return name.Space == "xmlns" || name.Space == "" && name.Local == "xmlns"
+}
+
+// xmlAlignment holds two XML trees aligned by alignXMLLists.
+type xmlAlignment struct {
+	tree1, tree2   interface{}
+	order1, order2 *keyOrder
+	// wrapped lists the paths at which an element of the first tree was
+	// wrapped in a list.
+	wrapped []Path
+}
+
+// alignXMLLists returns copies of two XML trees in which an element that
+// occurs once in one tree and repeatedly in the other is a list in both, so
+// that adding a second <dependency> reports an added list element rather
+// than a changed type.
+func alignXMLLists(doc1, doc2 *Document) *xmlAlignment {
+	x := &xmlAlignment{}
+	x.tree1, x.tree2, x.order1, x.order2 = x.align(doc1.Tree, doc2.Tree, Path{}, doc1.order, doc2.order)
+	return x
+}
+
+// align returns the aligned copies of a and b along with their key order.
+func (x *xmlAlignment) align(a, b interface{}, path Path, orderA, orderB *keyOrder) (interface{}, interface{}, *keyOrder, *keyOrder) {
+	switch ta := a.(type) {
+	case map[string]interface{}:
+		tb, ok := b.(map[string]interface{})
+		if !ok {
+			return a, b, orderA, orderB
+		}
+		ca := make(map[string]interface{}, len(ta))
+		cb := make(map[string]interface{}, len(tb))
+		oa, ob := orderA.mutable(), orderB.mutable()
+		for key, va := range ta {
+			vb, exists := tb[key]
+			if !exists {
+				ca[key] = va
+				continue
+			}
+			fa, fb := orderA.field(key), orderB.field(key)
+			switch {
+			case isList(va) && !isList(vb):
+				vb, fb = []interface{}{vb}, &keyOrder{elems: []*keyOrder{fb}}
+			case isList(vb) && !isList(va):
+				va, fa = []interface{}{va}, &keyOrder{elems: []*keyOrder{fa}}
+				x.wrapped = append(x.wrapped, path.Child(key))
+			}
+			ca[key], cb[key], fa, fb = x.align(va, vb, path.Child(key), fa, fb)
+			oa.setField(key, fa)
+			ob.setField(key, fb)
+		}
+		for key, vb := range tb {
+			if _, exists := ta[key]; !exists {
+				cb[key] = vb
+			}
+		}
+		return ca, cb, oa, ob
+	case []interface{}:
+		tb, ok := b.([]interface{})
+		if !ok {
+			return a, b, orderA, orderB
+		}
+		ca := append([]interface{}(nil), ta...)
+		cb := append([]interface{}(nil), tb...)
+		oa, ob := orderA.mutable(), orderB.mutable()
+		for i := 0; i < len(ca) && i < len(cb); i++ {
+			var fa, fb *keyOrder
+			ca[i], cb[i], fa, fb = x.align(ta[i], tb[i], path.Elem(i), orderA.elem(i), orderB.elem(i))
+			oa.setElem(i, fa)
+			ob.setElem(i, fb)
+		}
+		return ca, cb, oa, ob
+	}
+	return a, b, orderA, orderB
+}
+
+func isList(v interface{}) bool {
+	_, ok := v.([]interface{})
+	return ok
 }
@@ -2,7 +2,6 @@ package compare
 
 import (
 	"bytes"
-	"fmt"
 
 	"gopkg.in/yaml.v3"
 )
@@ -29,28 +28,70 @@ type YAMLComparator struct {
 	YAMLValidator
 }
 
-func (y *YAMLComparator) Compare(file1, file2 string, ignoreCase bool, config RemoteConfig) ([]Diff, error) {
-	data1, err := readFileContent(file1, config)
-	if err != nil {
-		return nil, fmt.Errorf("error reading first file: %w", err)
-	}
+func (y *YAMLComparator) Compare(doc1, doc2 *Document, opts Options) ([]Diff, error) {
+	return compareTrees(doc1, doc2, opts), nil
+}
 
-	data2, err := readFileContent(file2, config)
-	if err != nil {
-		return nil, fmt.Errorf("error reading second file: %w", err)
-	}
+func (y *YAMLComparator) Validator() FileValidator {
+	return &y.YAMLValidator
+}
 
-	var obj1, obj2 interface{}
-	if err := yaml.Unmarshal(data1, &obj1); err != nil {
-		return nil, fmt.Errorf("error parsing first file: %w", err)
+func (y *YAMLComparator) parse(data []byte, name string) (interface{}, *keyOrder, error) {
+	return decodeYAML(data)
+}
+
+// decodeYAML decodes a YAML document into its node tree, then into an
+// interface{}, and takes the key order of every mapping from the nodes.
+func decodeYAML(data []byte) (interface{}, *keyOrder, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, nil, err
 	}
-	if err := yaml.Unmarshal(data2, &obj2); err != nil {
-		return nil, fmt.Errorf("error parsing second file: %w", err)
+	if node.Kind == 0 {
+		return nil, nil, nil
 	}
-
-	return CompareValues(obj1, obj2, "", ignoreCase), nil
+	var value interface{}
+	if err := node.Decode(&value); err != nil {
+		return nil, nil, err
+	}
+	return value, yamlOrder(&node, value), nil
 }
 
-func (y *YAMLComparator) Validator() FileValidator {
-	return &y.YAMLValidator
+func yamlOrder(node *yaml.Node, value interface{}) *keyOrder {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) > 0 {
+			return yamlOrder(node.Content[0], value)
+		}
+	case yaml.AliasNode:
+		return yamlOrder(node.Alias, value)
+	case yaml.MappingNode:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		order := mapOrder(nil)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if key == "<<" {
+				continue
+			}
+			order.names = append(order.names, key)
+			order.setField(key, yamlOrder(node.Content[i+1], m[key]))
+		}
+		return order
+	case yaml.SequenceNode:
+		list, ok := value.([]interface{})
+		if !ok {
+			return nil
+		}
+		order := &keyOrder{}
+		for i, child := range node.Content {
+			if i < len(list) {
+				order.elems = append(order.elems, yamlOrder(child, list[i]))
+			}
+		}
+		return order
+	}
+	return nil
 }
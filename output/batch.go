@@ -0,0 +1,117 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/dolastack/structdiff/compare"
+	"github.com/fatih/color"
+)
+
+func FormatBatchText(results []compare.BatchResult, useColor bool) (string, error) {
+	var sb strings.Builder
+
+	if useColor {
+		color.NoColor = false
+	} else {
+		color.NoColor = true
+	}
+
+	var diffs []compare.Diff
+	for _, result := range results {
+		diffs = append(diffs, result.Diffs...)
+	}
+	total, parts := summaryParts(diffs)
+	sb.WriteString(withParts(fmt.Sprintf("Found %d differences", total), parts) + "\n")
+	sb.WriteString(generateBatchSummary(results) + "\n\n")
+
+	same := color.New(color.FgGreen).SprintFunc()
+	different := color.New(color.FgYellow).SprintFunc()
+	failed := color.New(color.FgRed).SprintFunc()
+
+	for _, result := range results {
+		switch result.Status {
+		case compare.FileUnchanged:
+			sb.WriteString(fmt.Sprintf("%s %s: identical\n", same("="), result.Name))
+		case compare.FileModified:
+			sb.WriteString(fmt.Sprintf("%s %s: %d differences\n", different("~"), result.Name, len(result.Diffs)))
+			writeDiffs(&sb, result.Diffs, "    ")
+		case compare.FileError:
+			sb.WriteString(fmt.Sprintf("%s %s: %s\n", failed("!"), result.Name,
+				strings.ReplaceAll(result.Error, "\n", "\n    ")))
+		}
+	}
+	return sb.String(), nil
+}
+
+func FormatBatchJSON(results []compare.BatchResult) (string, error) {
+	var diffs []compare.Diff
+	for _, result := range results {
+		diffs = append(diffs, result.Diffs...)
+	}
+	if results == nil {
+		results = []compare.BatchResult{}
+	}
+
+	output := struct {
+		Summary struct {
+			Total    int `json:"total"`
+			Added    int `json:"added"`
+			Removed  int `json:"removed"`
+			Modified int `json:"modified"`
+			Moved    int `json:"moved"`
+		} `json:"summary"`
+		PairSummary batchSummary          `json:"pairs_summary"`
+		Pairs       []compare.BatchResult `json:"pairs"`
+	}{
+		Summary:     generateSummaryStruct(diffs),
+		PairSummary: generateBatchSummaryStruct(results),
+		Pairs:       results,
+	}
+
+	data, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+type batchSummary struct {
+	Total     int `json:"total"`
+	Identical int `json:"identical"`
+	Different int `json:"different"`
+	Errors    int `json:"errors"`
+}
+
+func generateBatchSummary(results []compare.BatchResult) string {
+	summary := generateBatchSummaryStruct(results)
+
+	var parts []string
+	if summary.Different > 0 {
+		parts = append(parts, fmt.Sprintf("%d different", summary.Different))
+	}
+	if summary.Identical > 0 {
+		parts = append(parts, fmt.Sprintf("%d identical", summary.Identical))
+	}
+	if summary.Errors > 0 {
+		parts = append(parts, fmt.Sprintf("%d failed", summary.Errors))
+	}
+
+	return withParts(fmt.Sprintf("Compared %d pairs", summary.Total), parts)
+}
+
+func generateBatchSummaryStruct(results []compare.BatchResult) batchSummary {
+	summary := batchSummary{Total: len(results)}
+	for _, result := range results {
+		switch result.Status {
+		case compare.FileUnchanged:
+			summary.Identical++
+		case compare.FileModified:
+			summary.Different++
+		case compare.FileError:
+			summary.Errors++
+		}
+	}
+	return summary
+}
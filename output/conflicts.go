@@ -0,0 +1,57 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/dolastack/structdiff/compare"
+	"github.com/fatih/color"
+)
+
+func FormatConflictsText(conflicts []compare.Conflict, useColor bool) (string, error) {
+	var sb strings.Builder
+
+	if useColor {
+		color.NoColor = false
+	} else {
+		color.NoColor = true
+	}
+
+	sb.WriteString(fmt.Sprintf("Found %d conflicts\n", len(conflicts)))
+
+	marker := color.New(color.FgRed).SprintFunc()
+	for _, c := range conflicts {
+		sb.WriteString(fmt.Sprintf("\n%s %s\n", marker("!"), c.Path))
+		sb.WriteString(fmt.Sprintf("    base:   %s\n", conflictValue(c.Base, c.BaseAbsent)))
+		sb.WriteString(fmt.Sprintf("    ours:   %s\n", conflictValue(c.Ours, c.OursAbsent)))
+		sb.WriteString(fmt.Sprintf("    theirs: %s\n", conflictValue(c.Theirs, c.TheirsAbsent)))
+	}
+	return sb.String(), nil
+}
+
+func conflictValue(value interface{}, absent bool) string {
+	if absent {
+		return "(absent)"
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+func FormatConflictsJSON(conflicts []compare.Conflict) (string, error) {
+	output := struct {
+		Total     int                `json:"total"`
+		Conflicts []compare.Conflict `json:"conflicts"`
+	}{
+		Total:     len(conflicts),
+		Conflicts: conflicts,
+	}
+	if output.Conflicts == nil {
+		output.Conflicts = []compare.Conflict{}
+	}
+
+	data, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,64 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/dolastack/structdiff/compare"
+)
+
+// FormatCSVText is FormatText followed by the number of rows in which each
+// column changed.
+func FormatCSVText(diffs []compare.Diff, useColor bool) (string, error) {
+	text, err := FormatText(diffs, useColor)
+	if err != nil {
+		return "", err
+	}
+
+	changes := compare.CSVColumnChanges(diffs)
+	if len(changes) == 0 {
+		return text, nil
+	}
+	var sb strings.Builder
+	sb.WriteString(text + "\nChanged columns:\n")
+	for _, change := range changes {
+		rows := "rows"
+		if change.Rows == 1 {
+			rows = "row"
+		}
+		sb.WriteString(fmt.Sprintf("  %s: %d %s\n", change.Column, change.Rows, rows))
+	}
+	return sb.String(), nil
+}
+
+// FormatCSVJSON is FormatJSON with the changed columns under "columns".
+func FormatCSVJSON(diffs []compare.Diff) (string, error) {
+	output := struct {
+		Summary struct {
+			Total    int `json:"total"`
+			Added    int `json:"added"`
+			Removed  int `json:"removed"`
+			Modified int `json:"modified"`
+			Moved    int `json:"moved"`
+		} `json:"summary"`
+		Columns []compare.ColumnChange `json:"columns"`
+		Diffs   []compare.Diff         `json:"diffs"`
+	}{
+		Summary: generateSummaryStruct(diffs),
+		Columns: compare.CSVColumnChanges(diffs),
+		Diffs:   diffs,
+	}
+	if output.Columns == nil {
+		output.Columns = []compare.ColumnChange{}
+	}
+	if output.Diffs == nil {
+		output.Diffs = []compare.Diff{}
+	}
+
+	data, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,149 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/dolastack/structdiff/compare"
+	"github.com/fatih/color"
+)
+
+type fileSummary struct {
+	Total     int `json:"total"`
+	Added     int `json:"added"`
+	Removed   int `json:"removed"`
+	Modified  int `json:"modified"`
+	Unchanged int `json:"unchanged"`
+	Errors    int `json:"errors"`
+}
+
+func FormatDirText(files []compare.FileDiff, useColor bool) (string, error) {
+	var sb strings.Builder
+
+	if useColor {
+		color.NoColor = false
+	} else {
+		color.NoColor = true
+	}
+
+	total, parts := summaryParts(allDiffs(files))
+	sb.WriteString(withParts(fmt.Sprintf("Found %d differences", total), parts) + "\n")
+	sb.WriteString(generateFileSummary(files) + "\n\n")
+
+	added := color.New(color.FgGreen).SprintFunc()
+	removed := color.New(color.FgRed).SprintFunc()
+	modified := color.New(color.FgYellow).SprintFunc()
+	failed := color.New(color.FgRed).SprintFunc()
+
+	for _, file := range files {
+		switch file.Status {
+		case compare.FileAdded:
+			sb.WriteString(fmt.Sprintf("%s %s (added)\n", added("+"), file.Path))
+		case compare.FileRemoved:
+			sb.WriteString(fmt.Sprintf("%s %s (removed)\n", removed("-"), file.Path))
+		case compare.FileModified:
+			if len(file.Diffs) == 0 {
+				sb.WriteString(fmt.Sprintf("%s %s (contents differ)\n", modified("~"), file.Path))
+				continue
+			}
+			sb.WriteString(fmt.Sprintf("%s %s\n", modified("~"), file.Path))
+			writeDiffs(&sb, file.Diffs, "    ")
+		case compare.FileError:
+			sb.WriteString(fmt.Sprintf("%s %s: %s\n", failed("!"), file.Path,
+				strings.ReplaceAll(file.Error, "\n", "\n    ")))
+		}
+	}
+	return sb.String(), nil
+}
+
+func FormatDirJSON(files []compare.FileDiff) (string, error) {
+	type fileOutput struct {
+		compare.FileDiff
+		Summary *struct {
+			Total    int `json:"total"`
+			Added    int `json:"added"`
+			Removed  int `json:"removed"`
+			Modified int `json:"modified"`
+			Moved    int `json:"moved"`
+		} `json:"summary,omitempty"`
+	}
+	output := struct {
+		Summary struct {
+			Total    int `json:"total"`
+			Added    int `json:"added"`
+			Removed  int `json:"removed"`
+			Modified int `json:"modified"`
+			Moved    int `json:"moved"`
+		} `json:"summary"`
+		FileSummary fileSummary  `json:"files_summary"`
+		Files       []fileOutput `json:"files"`
+	}{
+		Summary:     generateSummaryStruct(allDiffs(files)),
+		FileSummary: generateFileSummaryStruct(files),
+		Files:       make([]fileOutput, len(files)),
+	}
+	for i, file := range files {
+		output.Files[i].FileDiff = file
+		if len(file.Diffs) > 0 {
+			summary := generateSummaryStruct(file.Diffs)
+			output.Files[i].Summary = &summary
+		}
+	}
+
+	data, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func allDiffs(files []compare.FileDiff) []compare.Diff {
+	var diffs []compare.Diff
+	for _, file := range files {
+		diffs = append(diffs, file.Diffs...)
+	}
+	return diffs
+}
+
+func generateFileSummary(files []compare.FileDiff) string {
+	summary := generateFileSummaryStruct(files)
+
+	var parts []string
+	if summary.Added > 0 {
+		parts = append(parts, fmt.Sprintf("%d added", summary.Added))
+	}
+	if summary.Removed > 0 {
+		parts = append(parts, fmt.Sprintf("%d removed", summary.Removed))
+	}
+	if summary.Modified > 0 {
+		parts = append(parts, fmt.Sprintf("%d modified", summary.Modified))
+	}
+	if summary.Unchanged > 0 {
+		parts = append(parts, fmt.Sprintf("%d unchanged", summary.Unchanged))
+	}
+	if summary.Errors > 0 {
+		parts = append(parts, fmt.Sprintf("%d failed", summary.Errors))
+	}
+
+	return withParts(fmt.Sprintf("Compared %d files", summary.Total), parts)
+}
+
+func generateFileSummaryStruct(files []compare.FileDiff) fileSummary {
+	summary := fileSummary{Total: len(files)}
+	for _, file := range files {
+		switch file.Status {
+		case compare.FileAdded:
+			summary.Added++
+		case compare.FileRemoved:
+			summary.Removed++
+		case compare.FileModified:
+			summary.Modified++
+		case compare.FileUnchanged:
+			summary.Unchanged++
+		case compare.FileError:
+			summary.Errors++
+		}
+	}
+	return summary
+}
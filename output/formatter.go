@@ -21,6 +21,11 @@ func FormatText(diffs []compare.Diff, useColor bool) (string, error) {
 	summary := generateSummary(diffs)
 	sb.WriteString(summary + "\n\n")
 
+	writeDiffs(&sb, diffs, "")
+	return sb.String(), nil
+}
+
+func writeDiffs(sb *strings.Builder, diffs []compare.Diff, indent string) {
 	added := color.New(color.FgGreen).SprintFunc()
 	removed := color.New(color.FgRed).SprintFunc()
 	modified := color.New(color.FgYellow).SprintFunc()
@@ -29,16 +34,15 @@ func FormatText(diffs []compare.Diff, useColor bool) (string, error) {
 	for _, diff := range diffs {
 		switch diff.Type {
 		case compare.DiffAdded:
-			sb.WriteString(fmt.Sprintf("%s %s: %v\n", added("+"), diff.Path, diff.NewValue))
+			sb.WriteString(fmt.Sprintf("%s%s %s: %v\n", indent, added("+"), diff.Path, diff.NewValue))
 		case compare.DiffRemoved:
-			sb.WriteString(fmt.Sprintf("%s %s: %v\n", removed("-"), diff.Path, diff.OldValue))
+			sb.WriteString(fmt.Sprintf("%s%s %s: %v\n", indent, removed("-"), diff.Path, diff.OldValue))
 		case compare.DiffModified:
-			sb.WriteString(fmt.Sprintf("%s %s: %v → %v\n", modified("~"), diff.Path, diff.OldValue, diff.NewValue))
+			sb.WriteString(fmt.Sprintf("%s%s %s: %v → %v\n", indent, modified("~"), diff.Path, diff.OldValue, diff.NewValue))
 		case compare.DiffMoved:
-			sb.WriteString(fmt.Sprintf("%s %s moved\n", moved(">"), diff.Path))
+			sb.WriteString(fmt.Sprintf("%s%s %s moved to %s\n", indent, moved(">"), diff.From, diff.Path))
 		}
 	}
-	return sb.String(), nil
 }
 
 func FormatJSON(diffs []compare.Diff) (string, error) {
@@ -55,6 +59,9 @@ func FormatJSON(diffs []compare.Diff) (string, error) {
 		Summary: generateSummaryStruct(diffs),
 		Diffs:   diffs,
 	}
+	if output.Diffs == nil {
+		output.Diffs = []compare.Diff{}
+	}
 
 	data, err := json.MarshalIndent(output, "", "  ")
 	if err != nil {
@@ -63,7 +70,40 @@ func FormatJSON(diffs []compare.Diff) (string, error) {
 	return string(data), nil
 }
 
+func FormatJSONPatch(diffs []compare.Diff) (string, error) {
+	return FormatPatchOperations(compare.JSONPatch(diffs))
+}
+
+// FormatPatchOperations renders JSON Patch operations.
+func FormatPatchOperations(ops []compare.PatchOperation) (string, error) {
+	if ops == nil {
+		ops = []compare.PatchOperation{}
+	}
+	data, err := json.MarshalIndent(ops, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func FormatMergePatch(diffs []compare.Diff, original interface{}) (string, error) {
+	patch, err := compare.MergePatch(original, diffs)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(patch, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
 func generateSummary(diffs []compare.Diff) string {
+	total, parts := summaryParts(diffs)
+	return fmt.Sprintf("Found %d differences (%s)", total, strings.Join(parts, ", "))
+}
+
+func summaryParts(diffs []compare.Diff) (int, []string) {
 	summary := generateSummaryStruct(diffs)
 
 	var parts []string
@@ -79,8 +119,15 @@ func generateSummary(diffs []compare.Diff) string {
 	if summary.Moved > 0 {
 		parts = append(parts, fmt.Sprintf("%d moved", summary.Moved))
 	}
+	return summary.Total, parts
+}
 
-	return fmt.Sprintf("Found %d differences (%s)", summary.Total, strings.Join(parts, ", "))
+// withParts appends the non-empty parts of a summary in parentheses.
+func withParts(summary string, parts []string) string {
+	if len(parts) == 0 {
+		return summary
+	}
+	return fmt.Sprintf("%s (%s)", summary, strings.Join(parts, ", "))
 }
 
 func generateSummaryStruct(diffs []compare.Diff) struct {